@@ -2,6 +2,9 @@ package main
 
 import (
     "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
     "log"
     "net/http"
     "os"
@@ -10,9 +13,19 @@ import (
     "syscall"
     "time"
 
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/audit"
     "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/httpapi"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/jwtauth"
     "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/observability"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/prober"
     "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/ratelimit"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/resp"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/risk"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/routing"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/tenant"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/tlsconfig"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/venue"
+    _ "github.com/jackc/pgx/v5/stdlib"
 )
 
 const (
@@ -21,6 +34,13 @@ const (
 )
 
 func main() {
+    if len(os.Args) > 1 && os.Args[1] == "audit-verify" {
+        os.Exit(runAuditVerify())
+    }
+    if len(os.Args) > 1 && os.Args[1] == "hash-api-key" {
+        os.Exit(runHashAPIKey(os.Args[2:]))
+    }
+
     ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
     defer stop()
 
@@ -38,8 +58,209 @@ func main() {
     port := getenv("PORT", defaultPort)
     limit := parseInt(getenv("RATE_LIMIT_PER_MIN", ""), defaultRequestsPerMin)
 
-    limiter := ratelimit.NewLimiter(limit, time.Minute)
-    server := httpapi.NewServer(limiter)
+    var serverOpts []httpapi.Option
+    if raw := getenv("ROUTING_WEIGHTS", ""); raw != "" {
+        weights, err := routing.ParseWeights(raw)
+        if err != nil {
+            log.Fatalf("invalid ROUTING_WEIGHTS: %v", err)
+        }
+        serverOpts = append(serverOpts, httpapi.WithScoringWeights(weights))
+    }
+
+    serverOpts = append(serverOpts, httpapi.WithBreakerConfig(routing.BreakerConfig{
+        FailureThreshold: parseInt(getenv("BREAKER_FAILURE_THRESHOLD", ""), routing.DefaultBreakerConfig().FailureThreshold),
+        OpenFor:          time.Duration(parseInt(getenv("BREAKER_OPEN_SECONDS", ""), 30)) * time.Second,
+    }))
+
+    metricConfig := routing.MetricConfig{
+        TTL:     time.Duration(parseInt(getenv("METRIC_TTL_SECONDS", ""), 30)) * time.Second,
+        Decay:   parseFloat(getenv("METRIC_DECAY", ""), routing.DefaultMetricConfig().Decay),
+        Window:  parseInt(getenv("METRIC_WINDOW", ""), routing.DefaultMetricConfig().Window),
+        Latency: getenv("METRIC_LATENCY", routing.LatencyEWMA),
+    }
+    switch metricConfig.Latency {
+    case routing.LatencyEWMA, routing.LatencyP50, routing.LatencyP99:
+    default:
+        log.Fatalf("unknown METRIC_LATENCY %q", metricConfig.Latency)
+    }
+    serverOpts = append(serverOpts, httpapi.WithMetricConfig(metricConfig))
+
+    if raw := getenv("TRUSTED_PROXIES", ""); raw != "" {
+        proxies, err := httpapi.ParseTrustedProxies(raw)
+        if err != nil {
+            log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+        }
+        header, err := httpapi.ParseTrustedProxyHeader(getenv("TRUSTED_PROXY_HEADER", ""))
+        if err != nil {
+            log.Fatalf("invalid TRUSTED_PROXY_HEADER: %v", err)
+        }
+        serverOpts = append(serverOpts, httpapi.WithTrustedProxies(proxies), httpapi.WithTrustedProxyHeader(header))
+    }
+
+    if raw := getenv("API_KEYS", ""); raw != "" {
+        keys, err := httpapi.ParseAPIKeys(raw)
+        if err != nil {
+            log.Fatalf("invalid API_KEYS: %v", err)
+        }
+        serverOpts = append(serverOpts, httpapi.WithAPIKeys(keys))
+    }
+
+    if source := getenv("JWT_JWKS", ""); source != "" {
+        verifier, err := jwtauth.NewVerifier(ctx, jwtauth.Config{
+            JWKS:     source,
+            Issuer:   getenv("JWT_ISSUER", ""),
+            Audience: getenv("JWT_AUDIENCE", ""),
+        })
+        if err != nil {
+            log.Fatalf("invalid JWT_JWKS: %v", err)
+        }
+        serverOpts = append(serverOpts, httpapi.WithJWTVerifier(verifier))
+    }
+
+    auditStore, err := openAuditStore(ctx, getenv("AUDIT_BACKEND", "memory"))
+    if err != nil {
+        log.Fatalf("failed to open audit store: %v", err)
+    }
+    defer auditStore.Close()
+    serverOpts = append(serverOpts, httpapi.WithAuditStore(audit.NewChain(auditStore)))
+
+    venueStore, err := openVenueStore(ctx, getenv("VENUE_BACKEND", "memory"))
+    if err != nil {
+        log.Fatalf("failed to open venue store: %v", err)
+    }
+    defer venueStore.Close()
+    serverOpts = append(serverOpts, httpapi.WithVenueStore(venueStore))
+    if interval := parseInt(getenv("PROBE_INTERVAL_SECONDS", ""), 0); interval > 0 {
+        allowed, err := prober.ParseNetworks(getenv("PROBE_ALLOWED_NETWORKS", ""))
+        if err != nil {
+            log.Fatalf("invalid PROBE_ALLOWED_NETWORKS: %v", err)
+        }
+        serverOpts = append(serverOpts, httpapi.WithProber(prober.Config{
+            Interval:        time.Duration(interval) * time.Second,
+            Timeout:         time.Duration(parseInt(getenv("PROBE_TIMEOUT_MS", ""), 2000)) * time.Millisecond,
+            Window:          parseInt(getenv("PROBE_WINDOW", ""), 30),
+            AllowedNetworks: allowed,
+        }))
+    }
+
+    limitConfig := ratelimit.Config{
+        Algorithm:   getenv("RATE_LIMIT_ALGORITHM", ratelimit.AlgorithmFixedWindow),
+        MaxRequests: limit,
+        Window:      time.Minute,
+        MaxKeys:     parseInt(getenv("RATE_LIMIT_MAX_KEYS", ""), ratelimit.DefaultMaxKeys),
+    }
+    if _, err := ratelimit.New(limitConfig); err != nil {
+        log.Fatalf("invalid RATE_LIMIT_ALGORITHM: %v", err)
+    }
+    // The rate limiter and the metric cache share one Redis client, opened
+    // only if either needs it.
+    var redisClient *resp.Client
+    sharedRedis := func() *resp.Client {
+        if redisClient == nil {
+            redisClient = newRedisClient()
+        }
+        return redisClient
+    }
+    defer func() {
+        if redisClient != nil {
+            redisClient.Close()
+        }
+    }()
+
+    var newLimiter func(config ratelimit.Config) ratelimit.Limiter
+    switch backend := getenv("RATE_LIMIT_BACKEND", "local"); backend {
+    case "local":
+        newLimiter = func(config ratelimit.Config) ratelimit.Limiter {
+            limiter, _ := ratelimit.New(config)
+            return limiter
+        }
+    case "redis":
+        client := sharedRedis()
+        if _, err := ratelimit.NewRedis(client, limitConfig); err != nil {
+            log.Fatalf("invalid RATE_LIMIT_ALGORITHM %q for RATE_LIMIT_BACKEND=redis: %v", limitConfig.Algorithm, err)
+        }
+        newLimiter = func(config ratelimit.Config) ratelimit.Limiter {
+            limiter, _ := ratelimit.NewRedis(client, config)
+            return limiter
+        }
+    default:
+        log.Fatalf("unknown RATE_LIMIT_BACKEND %q", backend)
+    }
+    limiter := newLimiter(limitConfig)
+    addressConfig := limitConfig
+    addressConfig.MaxRequests = parseInt(getenv("ADDRESS_RATE_LIMIT_PER_MIN", ""), httpapi.DefaultAddressRequestsPerMin)
+    serverOpts = append(serverOpts, httpapi.WithAddressRateLimit(newLimiter(addressConfig)))
+
+    switch backend := getenv("METRIC_BACKEND", "memory"); backend {
+    case "memory":
+    case "redis":
+        client := sharedRedis()
+        serverOpts = append(serverOpts, httpapi.WithMetricCache(func(tenant string, config routing.MetricConfig) routing.MetricCache {
+            return routing.NewRedisMetricCache(client, tenant, config)
+        }))
+    default:
+        log.Fatalf("unknown METRIC_BACKEND %q", backend)
+    }
+
+    if path := getenv("RISK_LIMITS_FILE", ""); path != "" {
+        limits, err := risk.LoadLimits(path)
+        if err != nil {
+            log.Fatalf("invalid RISK_LIMITS_FILE: %v", err)
+        }
+        serverOpts = append(serverOpts, httpapi.WithRiskLimits(limits))
+    }
+    if path := getenv("TENANTS_FILE", ""); path != "" {
+        tenants, err := tenant.LoadRegistry(path)
+        if err != nil {
+            log.Fatalf("invalid TENANTS_FILE: %v", err)
+        }
+        serverOpts = append(serverOpts, httpapi.WithTenants(tenants, func(requestsPerMin int) ratelimit.Limiter {
+            config := limitConfig
+            config.MaxRequests = requestsPerMin
+            return newLimiter(config)
+        }))
+    }
+    if reporter, ok := limiter.(ratelimit.StatsReporter); ok {
+        if err := ratelimit.RegisterMetrics(reporter); err != nil {
+            log.Printf("failed to register rate limit metrics: %v", err)
+        }
+    }
+    var tlsReloader *tlsconfig.Reloader
+    if certFile := getenv("TLS_CERT_FILE", ""); certFile != "" {
+        tlsReloader, err = tlsconfig.NewReloader(tlsconfig.Config{
+            CertFile:     certFile,
+            KeyFile:      getenv("TLS_KEY_FILE", ""),
+            ClientCAFile: getenv("TLS_CLIENT_CA_FILE", ""),
+            ClientAuth:   getenv("TLS_CLIENT_AUTH", tlsconfig.ClientAuthRequire),
+        })
+        if err != nil {
+            log.Fatalf("invalid TLS configuration: %v", err)
+        }
+        go tlsReloader.Watch(ctx, time.Duration(parseInt(getenv("TLS_RELOAD_SECONDS", ""), 10))*time.Second)
+        if tlsReloader.MutualTLS() {
+            scopes, err := httpapi.ParseClientCertScopes(getenv("CLIENT_CERT_SCOPES", ""))
+            if err != nil {
+                log.Fatalf("invalid CLIENT_CERT_SCOPES: %v", err)
+            }
+            serverOpts = append(serverOpts, httpapi.WithClientCertAuth(scopes))
+        }
+    }
+
+    server := httpapi.NewServer(limiter, serverOpts...)
+
+    // Restore before probing starts, so the snapshot never overwrites
+    // fresher probe results.
+    snapshotPath := getenv("METRIC_SNAPSHOT_FILE", "")
+    if snapshotPath != "" {
+        restored, err := server.RestoreMetrics(snapshotPath)
+        if err != nil {
+            log.Printf("failed to restore metric snapshot, starting cold: %v", err)
+        } else {
+            log.Printf("restored metrics for %d targets from %s", restored, snapshotPath)
+        }
+        go server.RunMetricSnapshots(ctx, snapshotPath, time.Duration(parseInt(getenv("METRIC_SNAPSHOT_SECONDS", ""), 30))*time.Second)
+    }
+    go server.RunProbes(ctx)
 
     httpServer := &http.Server{
         Addr:              ":" + port,
@@ -49,9 +270,16 @@ func main() {
         WriteTimeout:      5 * time.Second,
         IdleTimeout:       30 * time.Second,
     }
+    if tlsReloader != nil {
+        httpServer.TLSConfig = tlsReloader.TLSConfig()
+    }
 
     go func() {
-        if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+        serve := httpServer.ListenAndServe
+        if tlsReloader != nil {
+            serve = func() error { return httpServer.ListenAndServeTLS("", "") }
+        }
+        if err := serve(); err != nil && err != http.ErrServerClosed {
             log.Fatalf("server stopped unexpectedly: %v", err)
         }
     }()
@@ -61,6 +289,118 @@ func main() {
     shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
     _ = httpServer.Shutdown(shutdownCtx)
+    if snapshotPath != "" {
+        if err := server.SaveMetrics(snapshotPath); err != nil {
+            log.Printf("failed to save metric snapshot: %v", err)
+        }
+    }
+}
+
+func newRedisClient() *resp.Client {
+    return resp.NewClient(getenv("REDIS_ADDR", "localhost:6379"), resp.Options{
+        Password: getenv("REDIS_PASSWORD", ""),
+    })
+}
+
+// runAuditVerify implements "sor audit-verify": it walks the hash chain of
+// the configured durable audit backend, prints the report as JSON and exits
+// non-zero when a link is broken.
+func runAuditVerify() int {
+    backend := getenv("AUDIT_BACKEND", "memory")
+    if backend == "memory" {
+        log.Printf("audit-verify needs a durable AUDIT_BACKEND (file or postgres)")
+        return 2
+    }
+    store, err := openAuditStore(context.Background(), backend)
+    if err != nil {
+        log.Printf("failed to open audit store: %v", err)
+        return 2
+    }
+    defer store.Close()
+
+    report, err := audit.Verify(store)
+    if err != nil {
+        log.Printf("failed to verify audit chain: %v", err)
+        return 2
+    }
+    encoder := json.NewEncoder(os.Stdout)
+    encoder.SetIndent("", "  ")
+    _ = encoder.Encode(report)
+    if !report.Valid {
+        return 1
+    }
+    return 0
+}
+
+// runHashAPIKey prints the hash to configure in API_KEYS for a new key, so
+// the plaintext key never has to be stored alongside the service.
+func runHashAPIKey(args []string) int {
+    if len(args) != 1 || args[0] == "" {
+        log.Printf("usage: sor hash-api-key <key>")
+        return 2
+    }
+    fmt.Println(httpapi.HashAPIKey(args[0]))
+    return 0
+}
+
+// openAuditStore builds the audit backend named by AUDIT_BACKEND: "memory"
+// (the default), "file" or "postgres".
+func openAuditStore(ctx context.Context, backend string) (audit.Backend, error) {
+    switch backend {
+    case "memory":
+        return audit.NewStore(), nil
+    case "file":
+        segmentBytes := parseInt(getenv("AUDIT_SEGMENT_BYTES", ""), audit.DefaultSegmentBytes)
+        return audit.NewFileStore(getenv("AUDIT_DIR", "data/audit"), int64(segmentBytes))
+    case "postgres":
+        dsn := getenv("AUDIT_DATABASE_URL", "")
+        if dsn == "" {
+            return nil, fmt.Errorf("AUDIT_DATABASE_URL is required for the postgres audit backend")
+        }
+        db, err := sql.Open("pgx", dsn)
+        if err != nil {
+            return nil, err
+        }
+        initCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+        store, err := audit.NewPostgresStore(initCtx, db)
+        if err != nil {
+            _ = db.Close()
+            return nil, err
+        }
+        return store, nil
+    }
+    return nil, fmt.Errorf("unknown audit backend %q", backend)
+}
+
+// openVenueStore builds the venue registry named by VENUE_BACKEND: "memory"
+// (the default), "file" or "postgres". The postgres registry defaults to the
+// audit database.
+func openVenueStore(ctx context.Context, backend string) (venue.Store, error) {
+    switch backend {
+    case "memory":
+        return venue.NewMemoryStore(), nil
+    case "file":
+        return venue.NewFileStore(getenv("VENUE_FILE", "data/venues.json"))
+    case "postgres":
+        dsn := getenv("VENUE_DATABASE_URL", getenv("AUDIT_DATABASE_URL", ""))
+        if dsn == "" {
+            return nil, fmt.Errorf("VENUE_DATABASE_URL is required for the postgres venue backend")
+        }
+        db, err := sql.Open("pgx", dsn)
+        if err != nil {
+            return nil, err
+        }
+        initCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+        store, err := venue.NewPostgresStore(initCtx, db)
+        if err != nil {
+            _ = db.Close()
+            return nil, err
+        }
+        return store, nil
+    }
+    return nil, fmt.Errorf("unknown venue backend %q", backend)
 }
 
 func getenv(key, fallback string) string {
@@ -81,3 +421,14 @@ func parseInt(raw string, fallback int) int {
     }
     return value
 }
+
+func parseFloat(raw string, fallback float64) float64 {
+    if raw == "" {
+        return fallback
+    }
+    value, err := strconv.ParseFloat(raw, 64)
+    if err != nil || value <= 0 {
+        return fallback
+    }
+    return value
+}
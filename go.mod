@@ -3,6 +3,7 @@ module github.com/GuilhermeSoares009/smart-order-routing-engine
 go 1.22
 
 require (
+    github.com/jackc/pgx/v5 v5.5.5
     go.opentelemetry.io/otel v1.24.0
     go.opentelemetry.io/otel/exporters/stdout/stdoutmetric v1.24.0
     go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.24.0
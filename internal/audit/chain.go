@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+var errChainBroken = errors.New("audit chain broken")
+
+// Chain is a Backend decorator that numbers entries and links each one to
+// its predecessor with a SHA-256 hash, so altering, removing or reordering
+// stored entries is detectable with Verify.
+//
+// A backend shared between replicas, such as PostgresStore, numbers and
+// links entries itself while holding a lock across writers. Any other
+// backend must only be written by one Chain at a time, as the Chain keeps
+// the head of the chain in memory.
+type Chain struct {
+	mu      sync.Mutex
+	backend Backend
+	loaded  bool
+	seq     uint64
+	head    string
+}
+
+func NewChain(backend Backend) *Chain {
+	return &Chain{backend: backend}
+}
+
+// chainedBackend is a Backend that numbers and links entries atomically with
+// storing them, reading the head of the chain from storage every time.
+type chainedBackend interface {
+	addChained(entry Entry) error
+}
+
+func (c *Chain) Add(entry Entry) error {
+	if chained, ok := c.backend.(chainedBackend); ok {
+		return chained.addChained(entry)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.loaded {
+		latest, err := c.backend.Query(Query{Limit: 1})
+		if err != nil {
+			return err
+		}
+		if len(latest.Entries) > 0 {
+			c.seq = latest.Entries[0].Seq
+			c.head = latest.Entries[0].Hash
+		}
+		c.loaded = true
+	}
+
+	entry, err := link(entry, c.seq, c.head)
+	if err != nil {
+		return err
+	}
+	if err := c.backend.Add(entry); err != nil {
+		return err
+	}
+	c.seq = entry.Seq
+	c.head = entry.Hash
+	return nil
+}
+
+func (c *Chain) Query(query Query) (Page, error) {
+	return c.backend.Query(query)
+}
+
+func (c *Chain) Find(tenant, routeID string) (Entry, bool, error) {
+	return c.backend.Find(tenant, routeID)
+}
+
+func (c *Chain) FindOutcome(tenant, routeID string) (Entry, bool, error) {
+	return c.backend.FindOutcome(tenant, routeID)
+}
+
+func (c *Chain) Scan(fn func(Entry) error) error {
+	return c.backend.Scan(fn)
+}
+
+func (c *Chain) Close() error {
+	return c.backend.Close()
+}
+
+// link numbers entry as the successor of the entry numbered seq with hash
+// head, and hashes it.
+func link(entry Entry, seq uint64, head string) (Entry, error) {
+	entry.Seq = seq + 1
+	entry.PrevHash = head
+	hash, err := HashEntry(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.Hash = hash
+	return entry, nil
+}
+
+// HashEntry returns the hex SHA-256 of the entry's JSON encoding with the
+// Hash field cleared. PrevHash is part of the hashed content, which is what
+// links the chain.
+func HashEntry(entry Entry) (string, error) {
+	entry.Hash = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyReport summarizes a walk over the chain. Broken is nil when every
+// entry checked out.
+type VerifyReport struct {
+	Valid    bool        `json:"valid"`
+	Entries  int         `json:"entries"`
+	FirstSeq uint64      `json:"firstSeq,omitempty"`
+	LastSeq  uint64      `json:"lastSeq,omitempty"`
+	Head     string      `json:"head,omitempty"`
+	Broken   *BrokenLink `json:"broken,omitempty"`
+}
+
+// BrokenLink describes the first entry that failed verification.
+type BrokenLink struct {
+	Seq     uint64 `json:"seq"`
+	RouteID string `json:"routeId"`
+	Reason  string `json:"reason"`
+}
+
+// Verify walks backend oldest first and reports the first broken link. A
+// backend that only retains recent entries starts the walk at its oldest
+// entry, trusting that entry's PrevHash as the anchor.
+func Verify(backend Backend) (VerifyReport, error) {
+	report := VerifyReport{Valid: true}
+	var previous *Entry
+
+	err := backend.Scan(func(entry Entry) error {
+		reason := ""
+		expected, err := HashEntry(entry)
+		switch {
+		case err != nil:
+			return err
+		case entry.Seq == 0:
+			reason = "entry has no sequence number"
+		case previous == nil && entry.Seq == 1 && entry.PrevHash != "":
+			reason = "first entry links to a predecessor"
+		case previous != nil && entry.Seq != previous.Seq+1:
+			reason = "sequence gap or reordering"
+		case previous != nil && entry.PrevHash != previous.Hash:
+			reason = "previous hash mismatch"
+		case entry.Hash != expected:
+			reason = "entry hash mismatch"
+		}
+		if reason != "" {
+			report.Valid = false
+			report.Broken = &BrokenLink{Seq: entry.Seq, RouteID: entry.RouteID, Reason: reason}
+			return errChainBroken
+		}
+
+		if previous == nil {
+			report.FirstSeq = entry.Seq
+		}
+		report.Entries++
+		report.LastSeq = entry.Seq
+		report.Head = entry.Hash
+		previous = &entry
+		return nil
+	})
+	if err != nil && !errors.Is(err, errChainBroken) {
+		return VerifyReport{}, err
+	}
+	return report, nil
+}
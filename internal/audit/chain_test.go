@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestVerifyDetectsTamperedEntry(t *testing.T) {
+	store := NewStore()
+	chain := NewChain(store)
+	for i := 0; i < 5; i++ {
+		if err := chain.Add(Entry{RouteID: "route-" + strconv.Itoa(i), TargetID: "a", Score: float64(i)}); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+
+	report, err := Verify(chain)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !report.Valid || report.Entries != 5 || report.LastSeq != 5 {
+		t.Fatalf("expected a valid chain of 5 entries, got %+v", report)
+	}
+
+	store.entries[2].TargetID = "b"
+
+	report, err = Verify(chain)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if report.Valid || report.Broken == nil || report.Broken.Seq != 3 || report.Broken.Reason != "entry hash mismatch" {
+		t.Fatalf("expected entry 3 to be reported, got %+v", report)
+	}
+}
+
+func TestChainResumesFromExistingHead(t *testing.T) {
+	store := NewStore()
+	if err := NewChain(store).Add(Entry{RouteID: "first"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := NewChain(store).Add(Entry{RouteID: "second"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	report, err := Verify(store)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !report.Valid || report.LastSeq != 2 {
+		t.Fatalf("expected the second writer to continue the chain, got %+v", report)
+	}
+}
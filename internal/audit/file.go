@@ -0,0 +1,437 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	DefaultSegmentBytes = 16 << 20
+	segmentPattern      = "audit-*.jsonl"
+	// readBlockBytes is how much of a segment a backward read loads at once.
+	readBlockBytes = 64 << 10
+)
+
+// FileStore is an append-only Backend writing one JSON entry per line into
+// numbered segment files. A segment is closed once it reaches segmentBytes
+// and never written again. Every Add is synced to disk before returning.
+//
+// The position of each route's latest decision and outcome is indexed in
+// memory when the store opens, so Find reads a single entry. Reads never
+// hold the lock appends take; they only see entries whose append completed.
+type FileStore struct {
+	mu           sync.Mutex
+	dir          string
+	segmentBytes int64
+	current      *os.File
+	currentIndex int
+	currentSize  int64
+
+	indexMu   sync.RWMutex
+	decisions map[routeKey]position
+	outcomes  map[routeKey]position
+}
+
+type routeKey struct {
+	tenant  string
+	routeID string
+}
+
+// position locates one entry's line within the segments.
+type position struct {
+	segment int
+	offset  int64
+	length  int
+}
+
+func NewFileStore(dir string, segmentBytes int64) (*FileStore, error) {
+	if segmentBytes <= 0 {
+		segmentBytes = DefaultSegmentBytes
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	store := &FileStore{
+		dir:          dir,
+		segmentBytes: segmentBytes,
+		decisions:    make(map[routeKey]position),
+		outcomes:     make(map[routeKey]position),
+	}
+	segments, err := store.segments()
+	if err != nil {
+		return nil, err
+	}
+	index := 1
+	if len(segments) > 0 {
+		index = segments[len(segments)-1]
+	}
+	if err := store.open(index); err != nil {
+		return nil, err
+	}
+	err = store.scan(func(entry Entry, at position) error {
+		store.index(entry, at)
+		return nil
+	})
+	if err != nil {
+		_ = store.current.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *FileStore) Add(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.currentSize > 0 && s.currentSize+int64(len(data)) > s.segmentBytes {
+		if err := s.current.Close(); err != nil {
+			return err
+		}
+		if err := s.open(s.currentIndex + 1); err != nil {
+			return err
+		}
+	}
+
+	at := position{segment: s.currentIndex, offset: s.currentSize, length: len(data)}
+	written, err := s.current.Write(data)
+	s.currentSize += int64(written)
+	if err != nil {
+		return err
+	}
+	if err := s.current.Sync(); err != nil {
+		return err
+	}
+	s.index(entry, at)
+	return nil
+}
+
+// Query walks back from the cursor's position, so each page reads only the
+// entries it skips or returns, however long the history.
+func (s *FileStore) Query(query Query) (Page, error) {
+	from, err := parsePositionCursor(query.Cursor)
+	if err != nil {
+		return Page{}, err
+	}
+	collect := newCollector(query)
+	var last position
+	err = s.scanBackward(from, func(entry Entry, at position) bool {
+		kept := len(collect.entries)
+		more := collect.add(entry)
+		if len(collect.entries) > kept {
+			last = at
+		}
+		return more
+	})
+	if err != nil {
+		return Page{}, err
+	}
+	return collect.page(positionCursor(last)), nil
+}
+
+// positionCursor resumes a query with the entries written before at.
+func positionCursor(at position) string {
+	return fmt.Sprintf("pos:%d:%d", at.segment, at.offset)
+}
+
+// parsePositionCursor returns the position a cursor resumes before, the zero
+// position for the first page.
+func parsePositionCursor(cursor string) (position, error) {
+	if cursor == "" {
+		return position{}, nil
+	}
+	var at position
+	if _, err := fmt.Sscanf(cursor, "pos:%d:%d", &at.segment, &at.offset); err != nil || at.segment <= 0 || at.offset < 0 {
+		return position{}, ErrInvalidCursor
+	}
+	return at, nil
+}
+
+func (s *FileStore) Find(tenant, routeID string) (Entry, bool, error) {
+	return s.lookup(s.decisions, tenant, routeID)
+}
+
+func (s *FileStore) FindOutcome(tenant, routeID string) (Entry, bool, error) {
+	return s.lookup(s.outcomes, tenant, routeID)
+}
+
+func (s *FileStore) Scan(fn func(Entry) error) error {
+	return s.scan(func(entry Entry, _ position) error {
+		return fn(entry)
+	})
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current.Close()
+}
+
+// index records the position of a route's decision or outcome. Later
+// entries replace earlier ones, so the latest is found.
+func (s *FileStore) index(entry Entry, at position) {
+	key := routeKey{tenant: entry.Tenant, routeID: entry.RouteID}
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	switch {
+	case entry.IsDecision():
+		s.decisions[key] = at
+	case entry.Outcome != nil:
+		s.outcomes[key] = at
+	}
+}
+
+// lookup reads the entry index holds for the route.
+func (s *FileStore) lookup(index map[routeKey]position, tenant, routeID string) (Entry, bool, error) {
+	s.indexMu.RLock()
+	at, ok := index[routeKey{tenant: tenant, routeID: routeID}]
+	s.indexMu.RUnlock()
+	if !ok {
+		return Entry{}, false, nil
+	}
+	entry, err := s.readAt(at)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	return entry, true, nil
+}
+
+func (s *FileStore) readAt(at position) (Entry, error) {
+	file, err := os.Open(filepath.Join(s.dir, segmentName(at.segment)))
+	if err != nil {
+		return Entry{}, err
+	}
+	defer file.Close()
+	data := make([]byte, at.length)
+	if _, err := file.ReadAt(data, at.offset); err != nil {
+		return Entry{}, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Entry{}, fmt.Errorf("%s offset %d: %w", segmentName(at.segment), at.offset, err)
+	}
+	return entry, nil
+}
+
+// written returns the segments holding entries and how much of the active
+// one, the last, has been written. Readers stop there, so they never see a
+// line still being appended.
+func (s *FileStore) written() ([]int, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	segments, err := s.segments()
+	return segments, s.currentSize, err
+}
+
+// scan calls fn with every entry and its position, oldest first, stopping at
+// the first error fn returns.
+func (s *FileStore) scan(fn func(Entry, position) error) error {
+	segments, size, err := s.written()
+	if err != nil {
+		return err
+	}
+	for i, index := range segments {
+		limit := int64(-1)
+		if i == len(segments)-1 {
+			limit = size
+		}
+		if err := s.readSegment(index, limit, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanBackward calls fn with every entry written before from, or every
+// entry when from is the zero position, newest first, until fn returns
+// false.
+func (s *FileStore) scanBackward(from position, fn func(Entry, position) bool) error {
+	segments, size, err := s.written()
+	if err != nil {
+		return err
+	}
+	for i := len(segments) - 1; i >= 0; i-- {
+		end := int64(-1)
+		switch {
+		case from.segment > 0 && segments[i] > from.segment:
+			continue
+		case segments[i] == from.segment:
+			end = from.offset
+		case i == len(segments)-1:
+			end = size
+		}
+		more, err := s.readBackward(segments[i], end, fn)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// readBackward calls fn with the entries of segment that lie before end, or
+// all of them when end is negative, newest first. It reads the file in
+// blocks back from end. It reports whether fn asked for more.
+func (s *FileStore) readBackward(segment int, end int64, fn func(Entry, position) bool) (bool, error) {
+	file, err := os.Open(filepath.Join(s.dir, segmentName(segment)))
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	if end < 0 {
+		info, err := file.Stat()
+		if err != nil {
+			return false, err
+		}
+		end = info.Size()
+	}
+
+	// buffer holds the segment's bytes from start to end, where end is
+	// always the start of a line.
+	var buffer []byte
+	start := end
+	for len(buffer) > 0 || start > 0 {
+		cut := bytes.LastIndexByte(buffer[:max(len(buffer)-1, 0)], '\n')
+		if len(buffer) == 0 || (cut < 0 && start > 0) {
+			from := max(start-readBlockBytes, 0)
+			block := make([]byte, start-from, int(start-from)+len(buffer))
+			if _, err := file.ReadAt(block, from); err != nil {
+				return false, err
+			}
+			buffer = append(block, buffer...)
+			start = from
+			continue
+		}
+
+		line := buffer[cut+1:]
+		at := position{segment: segment, offset: start + int64(cut+1), length: len(line)}
+		buffer = buffer[:cut+1]
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var entry Entry
+			if err := json.Unmarshal(trimmed, &entry); err != nil {
+				return false, fmt.Errorf("%s offset %d: %w", segmentName(segment), at.offset, err)
+			}
+			if !fn(entry, at) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// open makes index the active segment, dropping a torn trailing line left by
+// a crash mid-write so the next append starts on a clean line.
+func (s *FileStore) open(index int) error {
+	path := filepath.Join(s.dir, segmentName(index))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	size, err := repairTail(file)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	s.current = file
+	s.currentIndex = index
+	s.currentSize = size
+	return nil
+}
+
+func (s *FileStore) segments() ([]int, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, segmentPattern))
+	if err != nil {
+		return nil, err
+	}
+	indexes := make([]int, 0, len(matches))
+	for _, match := range matches {
+		var index int
+		if _, err := fmt.Sscanf(filepath.Base(match), "audit-%d.jsonl", &index); err == nil {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+func segmentName(index int) string {
+	return fmt.Sprintf("audit-%08d.jsonl", index)
+}
+
+func repairTail(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return 0, nil
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, size-1); err != nil {
+		return 0, err
+	}
+	if last[0] == '\n' {
+		return size, nil
+	}
+
+	data, err := io.ReadAll(io.NewSectionReader(file, 0, size))
+	if err != nil {
+		return 0, err
+	}
+	size = int64(bytes.LastIndexByte(data, '\n') + 1)
+	if err := file.Truncate(size); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// readSegment calls fn with every entry in the first limit bytes of segment,
+// or all of it when limit is negative, stopping at the first error fn
+// returns.
+func (s *FileStore) readSegment(segment int, limit int64, fn func(Entry, position) error) error {
+	file, err := os.Open(filepath.Join(s.dir, segmentName(segment)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var source io.Reader = file
+	if limit >= 0 {
+		source = io.LimitReader(file, limit)
+	}
+
+	reader := bufio.NewReader(source)
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		at := position{segment: segment, offset: offset, length: len(data)}
+		offset += int64(len(data))
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 {
+			var entry Entry
+			if err := json.Unmarshal(trimmed, &entry); err != nil {
+				return fmt.Errorf("%s line %d: %w", segmentName(segment), line, err)
+			}
+			if err := fn(entry, at); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFileStoreRotatesAndSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, 512)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		entry := Entry{Timestamp: time.Unix(int64(i), 0).UTC(), RouteID: "route-" + strconv.Itoa(i), TargetID: "a"}
+		if err := store.Add(entry); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	segments, _ := filepath.Glob(filepath.Join(dir, segmentPattern))
+	if len(segments) < 2 {
+		t.Fatalf("expected several segments, got %d", len(segments))
+	}
+
+	reopened, err := NewFileStore(dir, 512)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+
+	page, err := reopened.Query(Query{Limit: 3})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	entries := page.Entries
+	if len(entries) != 3 || entries[0].RouteID != "route-19" || entries[2].RouteID != "route-17" {
+		t.Fatalf("unexpected newest entries: %+v", entries)
+	}
+
+	found, ok, err := reopened.Find("", "route-2")
+	if err != nil || !ok || found.RouteID != "route-2" {
+		t.Fatalf("expected to find route-2 in an older segment, got %+v %v %v", found, ok, err)
+	}
+}
+
+func TestFileStoreDropsTornTrailingLine(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Add(Entry{RouteID: "complete"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	_ = store.Close()
+
+	path := filepath.Join(dir, segmentName(1))
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open segment: %v", err)
+	}
+	_, _ = file.WriteString(`{"routeId":"tor`)
+	_ = file.Close()
+
+	reopened, err := NewFileStore(dir, 0)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if err := reopened.Add(Entry{RouteID: "after-crash"}); err != nil {
+		t.Fatalf("add after crash: %v", err)
+	}
+
+	page, err := reopened.Query(Query{Limit: 10})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	entries := page.Entries
+	if len(entries) != 2 || entries[0].RouteID != "after-crash" || entries[1].RouteID != "complete" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestFileStoreFindsRoutesWithoutWaitingForAppends(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, 512)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := store.Add(Entry{RouteID: "route-" + strconv.Itoa(i), TargetID: "a"}); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+	_ = store.Add(Entry{RouteID: "route-3", TargetID: "a", Outcome: &Outcome{Status: "fill"}})
+	_ = store.Add(Entry{RouteID: "route-5", TargetID: "b"})
+	_ = store.Close()
+
+	reopened, err := NewFileStore(dir, 512)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+
+	// Hold the append lock as a slow Add would.
+	reopened.mu.Lock()
+	defer reopened.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if found, ok, err := reopened.Find("", "route-5"); err != nil || !ok || found.TargetID != "b" {
+			t.Errorf("expected the latest decision of route-5, got %+v %v (%v)", found, ok, err)
+		}
+		if found, ok, err := reopened.FindOutcome("", "route-3"); err != nil || !ok || found.Outcome.Status != "fill" {
+			t.Errorf("expected the outcome of route-3, got %+v %v (%v)", found, ok, err)
+		}
+		if _, ok, err := reopened.FindOutcome("", "route-4"); err != nil || ok {
+			t.Errorf("expected route-4 to have no outcome (%v)", err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lookups waited for the append lock")
+	}
+}
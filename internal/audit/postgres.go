@@ -0,0 +1,237 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS audit_entries (
+		seq         BIGSERIAL PRIMARY KEY,
+		recorded_at TIMESTAMPTZ NOT NULL,
+		route_id    TEXT NOT NULL,
+		order_id    TEXT NOT NULL,
+		target_id   TEXT NOT NULL,
+		is_outcome  BOOLEAN NOT NULL,
+		entry       JSONB NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS audit_entries_route_id_idx ON audit_entries (route_id, seq DESC)`,
+	`CREATE INDEX IF NOT EXISTS audit_entries_order_id_idx ON audit_entries (order_id, seq DESC)`,
+	// Two entries with one chain sequence number mean two writers forked the
+	// chain; the unique index refuses the second instead of storing it.
+	`CREATE UNIQUE INDEX IF NOT EXISTS audit_entries_chain_seq_key ON audit_entries (((entry->>'seq')::bigint))`,
+	`DROP INDEX IF EXISTS audit_entries_chain_seq_idx`,
+}
+
+// auditChainLock is the transaction-level advisory lock that serializes
+// chained appends across every replica sharing the database.
+const auditChainLock int64 = 0x736f722d61756469
+
+// PostgresStore is a Backend keeping entries in the audit_entries table. The
+// full entry is stored as JSONB next to the columns used for lookups.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates the audit schema if needed. The store owns db and
+// closes it on Close.
+func NewPostgresStore(ctx context.Context, db *sql.DB) (*PostgresStore, error) {
+	for _, statement := range postgresSchema {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return nil, err
+		}
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) Add(entry Entry) error {
+	return insertEntry(s.db, entry)
+}
+
+// addChained links entry to the latest stored one and inserts it in one
+// transaction holding auditChainLock, so replicas appending concurrently
+// cannot fork the chain.
+func (s *PostgresStore) addChained(entry Entry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditChainLock); err != nil {
+		return err
+	}
+	var (
+		seq  uint64
+		head string
+	)
+	latest, err := scanEntry(tx.QueryRow(`SELECT entry FROM audit_entries ORDER BY seq DESC LIMIT 1`))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return err
+	default:
+		seq, head = latest.Seq, latest.Hash
+	}
+	if entry, err = link(entry, seq, head); err != nil {
+		return err
+	}
+	if err := insertEntry(tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func insertEntry(db execer, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		`INSERT INTO audit_entries (recorded_at, route_id, order_id, target_id, is_outcome, entry)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		entry.Timestamp, entry.RouteID, entry.OrderID, entry.TargetID, entry.Outcome != nil, data,
+	)
+	return err
+}
+
+func (s *PostgresStore) Query(query Query) (Page, error) {
+	before, err := parseSeqCursor(query.Cursor)
+	if err != nil {
+		return Page{}, err
+	}
+	var (
+		clauses []string
+		args    []any
+	)
+	where := func(clause string, arg any) {
+		args = append(args, arg)
+		clauses = append(clauses, strings.ReplaceAll(clause, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if query.Tenant != nil {
+		where("COALESCE(entry->>'tenant', '') = ?", *query.Tenant)
+	}
+	if before > 0 {
+		where("(entry->>'seq')::bigint < ?", int64(before))
+	}
+	if query.OrderID != "" {
+		where("order_id = ?", query.OrderID)
+	}
+	if query.RouteID != "" {
+		where("route_id = ?", query.RouteID)
+	}
+	if query.TargetID != "" {
+		where("target_id = ?", query.TargetID)
+	}
+	if query.Fallback != nil {
+		where("(entry->>'fallback')::boolean = ?", *query.Fallback)
+	}
+	if !query.From.IsZero() {
+		where("recorded_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		where("recorded_at < ?", query.To)
+	}
+
+	statement := "SELECT entry FROM audit_entries"
+	if len(clauses) > 0 {
+		statement += " WHERE " + strings.Join(clauses, " AND ")
+	}
+	limit := query.limit()
+	args = append(args, limit+1)
+	statement += " ORDER BY seq DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return Page{}, err
+	}
+	defer rows.Close()
+
+	collect := newCollector(Query{Limit: limit})
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return Page{}, err
+		}
+		collect.add(entry)
+	}
+	return collect.page(collect.seqCursor()), rows.Err()
+}
+
+func (s *PostgresStore) Find(tenant, routeID string) (Entry, bool, error) {
+	return s.findRoute(
+		`SELECT entry FROM audit_entries
+		 WHERE route_id = $1 AND COALESCE(entry->>'tenant', '') = $2 AND NOT is_outcome AND entry->'rejection' IS NULL
+		 ORDER BY seq DESC LIMIT 1`,
+		tenant, routeID,
+	)
+}
+
+func (s *PostgresStore) FindOutcome(tenant, routeID string) (Entry, bool, error) {
+	return s.findRoute(
+		`SELECT entry FROM audit_entries
+		 WHERE route_id = $1 AND COALESCE(entry->>'tenant', '') = $2 AND is_outcome
+		 ORDER BY seq DESC LIMIT 1`,
+		tenant, routeID,
+	)
+}
+
+// findRoute runs statement, which selects at most one entry by route ID ($1)
+// and tenant ($2).
+func (s *PostgresStore) findRoute(statement, tenant, routeID string) (Entry, bool, error) {
+	entry, err := scanEntry(s.db.QueryRow(statement, routeID, tenant))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, err
+	}
+	return entry, true, nil
+}
+
+func (s *PostgresStore) Scan(fn func(Entry) error) error {
+	rows, err := s.db.Query(`SELECT entry FROM audit_entries ORDER BY seq ASC`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEntry(row rowScanner) (Entry, error) {
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		return Entry{}, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultQueryLimit = 50
+	MaxQueryLimit     = 500
+)
+
+// Query selects audit entries, newest first. Empty fields do not filter.
+// From is inclusive and To exclusive. Cursor is the NextCursor of the
+// previous page, an opaque position the backend resumes from; entries
+// appended since do not shift later pages. A non-nil Tenant restricts the
+// query to that tenant's partition, "" being the default tenant.
+type Query struct {
+	Tenant   *string
+	OrderID  string
+	RouteID  string
+	TargetID string
+	Fallback *bool
+	From     time.Time
+	To       time.Time
+	Cursor   string
+	Limit    int
+}
+
+// Page is one page of query results. NextCursor is empty on the last page.
+type Page struct {
+	Entries    []Entry
+	NextCursor string
+}
+
+// ErrInvalidCursor is returned by Query for a cursor the backend did not
+// issue.
+var ErrInvalidCursor = errors.New("invalid audit cursor")
+
+// seqCursor is the cursor of backends that page by chain sequence number,
+// resuming below seq.
+func seqCursor(seq uint64) string {
+	return "seq:" + strconv.FormatUint(seq, 10)
+}
+
+// parseSeqCursor returns the sequence number cursor resumes below, or zero
+// for the first page.
+func parseSeqCursor(cursor string) (uint64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	value, ok := strings.CutPrefix(cursor, "seq:")
+	if !ok {
+		return 0, ErrInvalidCursor
+	}
+	seq, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || seq == 0 {
+		return 0, ErrInvalidCursor
+	}
+	return seq, nil
+}
+
+func (q Query) limit() int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultQueryLimit
+	case q.Limit > MaxQueryLimit:
+		return MaxQueryLimit
+	}
+	return q.Limit
+}
+
+func (q Query) matches(entry Entry) bool {
+	switch {
+	case q.Tenant != nil && entry.Tenant != *q.Tenant:
+		return false
+	case q.OrderID != "" && entry.OrderID != q.OrderID:
+		return false
+	case q.RouteID != "" && entry.RouteID != q.RouteID:
+		return false
+	case q.TargetID != "" && entry.TargetID != q.TargetID:
+		return false
+	case q.Fallback != nil && entry.Fallback != *q.Fallback:
+		return false
+	case !q.From.IsZero() && entry.Timestamp.Before(q.From):
+		return false
+	case !q.To.IsZero() && !entry.Timestamp.Before(q.To):
+		return false
+	}
+	return true
+}
+
+// collector gathers matches from a newest-first walk, reading one entry past
+// the limit to learn whether another page exists.
+type collector struct {
+	query   Query
+	limit   int
+	entries []Entry
+	more    bool
+}
+
+func newCollector(query Query) *collector {
+	limit := query.limit()
+	return &collector{query: query, limit: limit, entries: make([]Entry, 0, limit)}
+}
+
+// add reports whether the walk should continue.
+func (c *collector) add(entry Entry) bool {
+	if !c.query.matches(entry) {
+		return true
+	}
+	if len(c.entries) == c.limit {
+		c.more = true
+		return false
+	}
+	c.entries = append(c.entries, entry)
+	return true
+}
+
+// page returns the collected entries, with next as the cursor when another
+// page exists.
+func (c *collector) page(next string) Page {
+	page := Page{Entries: c.entries}
+	if c.more && len(c.entries) > 0 {
+		page.NextCursor = next
+	}
+	return page
+}
+
+// seqCursor is the cursor resuming after the last collected entry by its
+// sequence number.
+func (c *collector) seqCursor() string {
+	if len(c.entries) == 0 {
+		return ""
+	}
+	return seqCursor(c.entries[len(c.entries)-1].Seq)
+}
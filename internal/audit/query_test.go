@@ -0,0 +1,185 @@
+package audit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryPagesWithoutGapsWhileAppending(t *testing.T) {
+	// Small segments make the file store's pages span several of them.
+	file, err := NewFileStore(t.TempDir(), 512)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+
+	for _, backend := range []Backend{NewStore(), file} {
+		testQueryPagesWithoutGaps(t, backend)
+	}
+}
+
+func testQueryPagesWithoutGaps(t *testing.T, backend Backend) {
+	chain := NewChain(backend)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		target := "a"
+		if i%2 == 1 {
+			target = "b"
+		}
+		if err := chain.Add(Entry{Timestamp: base.Add(time.Duration(i) * time.Minute), TargetID: target}); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+
+	first, err := chain.Query(Query{TargetID: "a", Limit: 2})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(first.Entries) != 2 || first.Entries[0].Seq != 7 || first.Entries[1].Seq != 5 || first.NextCursor == "" {
+		t.Fatalf("%T: unexpected first page: %+v", backend, first)
+	}
+
+	// Entries appended between pages must not shift the next page.
+	if err := chain.Add(Entry{Timestamp: base.Add(time.Hour), TargetID: "a"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	second, err := chain.Query(Query{TargetID: "a", Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(second.Entries) != 2 || second.Entries[0].Seq != 3 || second.Entries[1].Seq != 1 || second.NextCursor != "" {
+		t.Fatalf("%T: unexpected second page: %+v", backend, second)
+	}
+
+	window, err := chain.Query(Query{From: base.Add(2 * time.Minute), To: base.Add(4 * time.Minute)})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(window.Entries) != 2 || window.Entries[0].Seq != 4 || window.Entries[1].Seq != 3 {
+		t.Fatalf("%T: unexpected time window: %+v", backend, window)
+	}
+}
+
+func TestFileStorePagesThroughLargeHistories(t *testing.T) {
+	file, err := NewFileStore(t.TempDir(), 256<<10)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+
+	// Enough entries to fill several segments, each read in several blocks.
+	chain := NewChain(file)
+	const total = 3000
+	for i := 0; i < total; i++ {
+		if err := chain.Add(Entry{Timestamp: time.Now(), RouteID: strings.Repeat("r", i%200), TargetID: "a"}); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+
+	next := uint64(total)
+	query := Query{Limit: 37}
+	for {
+		page, err := chain.Query(query)
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		for _, entry := range page.Entries {
+			if entry.Seq != next {
+				t.Fatalf("expected seq %d, got %d", next, entry.Seq)
+			}
+			next--
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	if next != 0 {
+		t.Fatalf("paging stopped before seq %d", next)
+	}
+
+	for _, cursor := range []string{"seq:5", "pos:0:10", "pos:1:-1"} {
+		if _, err := chain.Query(Query{Cursor: cursor}); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("expected %q to be rejected, got %v", cursor, err)
+		}
+	}
+}
+
+func TestQueryTenantPartition(t *testing.T) {
+	dir := t.TempDir()
+	file, err := NewFileStore(dir, DefaultSegmentBytes)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+
+	for _, backend := range []Backend{NewStore(), file} {
+		chain := NewChain(backend)
+		for _, tenant := range []string{"", "acme", "globex", "acme"} {
+			if err := chain.Add(Entry{Timestamp: time.Now(), RouteID: "r", Tenant: tenant}); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+		}
+
+		acme, defaultTenant := "acme", ""
+		page, err := chain.Query(Query{Tenant: &acme})
+		if err != nil || len(page.Entries) != 2 || page.Entries[0].Tenant != "acme" || page.Entries[1].Tenant != "acme" {
+			t.Fatalf("%T: unexpected acme page %+v (%v)", backend, page, err)
+		}
+		page, err = chain.Query(Query{Tenant: &defaultTenant})
+		if err != nil || len(page.Entries) != 1 || page.Entries[0].Seq != 1 {
+			t.Fatalf("%T: unexpected default tenant page %+v (%v)", backend, page, err)
+		}
+		page, err = chain.Query(Query{})
+		if err != nil || len(page.Entries) != 4 {
+			t.Fatalf("%T: expected every tenant without a filter, got %+v (%v)", backend, page, err)
+		}
+	}
+}
+
+func TestFindSkipsRejectionsOutcomesAndOtherTenants(t *testing.T) {
+	file, err := NewFileStore(t.TempDir(), DefaultSegmentBytes)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+
+	for _, backend := range []Backend{NewStore(), file} {
+		chain := NewChain(backend)
+		entries := []Entry{
+			{RouteID: "r", TargetID: "nyse"},
+			{RouteID: "r", TargetID: "nyse", Outcome: &Outcome{Status: "fill"}},
+			{RouteID: "r", Rejection: &Rejection{Code: "MAX_QUANTITY_EXCEEDED"}},
+			{RouteID: "rejected", Rejection: &Rejection{Code: "RESTRICTED_SYMBOL"}},
+			{RouteID: "r", TargetID: "lse", Tenant: "other"},
+		}
+		for _, entry := range entries {
+			entry.Timestamp = time.Now()
+			if err := chain.Add(entry); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+		}
+
+		if found, ok, err := chain.Find("", "r"); err != nil || !ok || found.Seq != 1 {
+			t.Fatalf("%T: expected the decision entry, got %+v %v (%v)", backend, found, ok, err)
+		}
+		if _, ok, err := chain.Find("", "rejected"); err != nil || ok {
+			t.Fatalf("%T: expected a rejected route to have no decision (%v)", backend, err)
+		}
+		if found, ok, err := chain.Find("other", "r"); err != nil || !ok || found.TargetID != "lse" {
+			t.Fatalf("%T: expected the other tenant's own decision, got %+v %v (%v)", backend, found, ok, err)
+		}
+		if _, ok, err := chain.Find("missing", "r"); err != nil || ok {
+			t.Fatalf("%T: expected routes of other tenants to stay hidden (%v)", backend, err)
+		}
+		if found, ok, err := chain.FindOutcome("", "r"); err != nil || !ok || found.Seq != 2 {
+			t.Fatalf("%T: expected the outcome entry, got %+v %v (%v)", backend, found, ok, err)
+		}
+		if _, ok, err := chain.FindOutcome("other", "r"); err != nil || ok {
+			t.Fatalf("%T: expected another tenant's route to have no outcome (%v)", backend, err)
+		}
+	}
+}
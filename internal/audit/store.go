@@ -5,17 +5,73 @@ import (
 	"time"
 )
 
+// Entry is one audited routing decision or outcome. Fields added later must
+// be omitempty so the hashes of entries written before them still verify.
 type Entry struct {
-	Timestamp   time.Time `json:"timestamp"`
-	RouteID     string    `json:"routeId"`
-	OrderID     string    `json:"orderId"`
-	TargetID    string    `json:"targetId"`
-	Reason      string    `json:"reason"`
-	Fallback    bool      `json:"fallback"`
-	Score       float64   `json:"score"`
-	TargetCount int       `json:"targetCount"`
+	Seq           uint64     `json:"seq,omitempty"`
+	PrevHash      string     `json:"prevHash,omitempty"`
+	Hash          string     `json:"hash,omitempty"`
+	Timestamp     time.Time  `json:"timestamp"`
+	RouteID       string     `json:"routeId"`
+	ParentRouteID string     `json:"parentRouteId,omitempty"`
+	OrderID       string     `json:"orderId"`
+	TargetID      string     `json:"targetId"`
+	Quantity      int64      `json:"quantity"`
+	Reason        string     `json:"reason"`
+	Fallback      bool       `json:"fallback"`
+	Score         float64    `json:"score"`
+	Strategy      string     `json:"strategy"`
+	TargetCount   int        `json:"targetCount"`
+	Outcome       *Outcome   `json:"outcome,omitempty"`
+	ClientID      string     `json:"clientId,omitempty"`
+	Tenant        string     `json:"tenant,omitempty"`
+	Rejection     *Rejection `json:"rejection,omitempty"`
 }
 
+// IsDecision reports whether the entry records a routing decision rather
+// than an outcome or a rejection.
+func (e Entry) IsDecision() bool {
+	return e.Outcome == nil && e.Rejection == nil
+}
+
+// Outcome is the execution result a client reported for a route. Outcomes
+// are appended as their own entries, repeating the route's identifiers, so
+// the decision entry itself is never modified.
+type Outcome struct {
+	Status    string `json:"status"`
+	LatencyMs *int64 `json:"latencyMs,omitempty"`
+}
+
+// Rejection records an order refused before routing, with the order details
+// the pre-trade risk checks judged it on. Rejected orders have no target.
+type Rejection struct {
+	Code    string   `json:"code"`
+	Message string   `json:"message"`
+	Symbol  string   `json:"symbol"`
+	Side    string   `json:"side"`
+	Price   *float64 `json:"price,omitempty"`
+}
+
+// Backend persists audit entries. Implementations must be safe for
+// concurrent use and never modify an entry once it has been added.
+type Backend interface {
+	Add(entry Entry) error
+	// Query returns one page of matching entries, newest first.
+	Query(query Query) (Page, error)
+	// Find returns the most recent routing decision tenant recorded for
+	// routeID, ignoring outcome and rejection entries. Route IDs are chosen
+	// by clients, so only the tenant's own entries are considered.
+	Find(tenant, routeID string) (Entry, bool, error)
+	// FindOutcome returns the outcome tenant reported for routeID, if any.
+	FindOutcome(tenant, routeID string) (Entry, bool, error)
+	// Scan calls fn with every entry, oldest first, stopping at the first
+	// error fn returns.
+	Scan(fn func(Entry) error) error
+	Close() error
+}
+
+// Store is the in-memory Backend. It keeps the latest 1000 entries and loses
+// everything on restart.
 type Store struct {
 	mu      sync.Mutex
 	entries []Entry
@@ -25,7 +81,7 @@ func NewStore() *Store {
 	return &Store{entries: make([]Entry, 0, 200)}
 }
 
-func (s *Store) Add(entry Entry) {
+func (s *Store) Add(entry Entry) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -33,25 +89,67 @@ func (s *Store) Add(entry Entry) {
 	if len(s.entries) > 1000 {
 		s.entries = s.entries[len(s.entries)-1000:]
 	}
+	return nil
 }
 
-func (s *Store) List(limit int) []Entry {
+func (s *Store) Query(query Query) (Page, error) {
+	before, err := parseSeqCursor(query.Cursor)
+	if err != nil {
+		return Page{}, err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if limit <= 0 {
-		limit = 50
+	collect := newCollector(query)
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if before > 0 && s.entries[i].Seq >= before {
+			continue
+		}
+		if !collect.add(s.entries[i]) {
+			break
+		}
 	}
-	if limit > len(s.entries) {
-		limit = len(s.entries)
+	return collect.page(collect.seqCursor()), nil
+}
+
+func (s *Store) Find(tenant, routeID string) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if s.entries[i].Tenant == tenant && s.entries[i].RouteID == routeID && s.entries[i].IsDecision() {
+			return s.entries[i], true, nil
+		}
 	}
-	start := len(s.entries) - limit
-	if start < 0 {
-		start = 0
+	return Entry{}, false, nil
+}
+
+func (s *Store) FindOutcome(tenant, routeID string) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if s.entries[i].Tenant == tenant && s.entries[i].RouteID == routeID && s.entries[i].Outcome != nil {
+			return s.entries[i], true, nil
+		}
 	}
-	result := make([]Entry, 0, limit)
-	for i := len(s.entries) - 1; i >= start; i-- {
-		result = append(result, s.entries[i])
+	return Entry{}, false, nil
+}
+
+func (s *Store) Scan(fn func(Entry) error) error {
+	s.mu.Lock()
+	entries := make([]Entry, len(s.entries))
+	copy(entries, s.entries)
+	s.mu.Unlock()
+
+	for _, entry := range entries {
+		if err := fn(entry); err != nil {
+			return err
+		}
 	}
-	return result
+	return nil
+}
+
+func (s *Store) Close() error {
+	return nil
 }
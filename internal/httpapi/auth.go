@@ -0,0 +1,361 @@
+package httpapi
+
+import (
+    "context"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/jwtauth"
+)
+
+// APIKeyHeader carries the caller's API key.
+const APIKeyHeader = "X-API-Key"
+
+// ErrInvalidAPIKeys is returned by ParseAPIKeys for malformed configuration.
+var ErrInvalidAPIKeys = errors.New("invalid api keys")
+
+// ErrInvalidClientCertScopes is returned by ParseClientCertScopes for
+// malformed configuration.
+var ErrInvalidClientCertScopes = errors.New("invalid client certificate scopes")
+
+var errMissingCredentials = errors.New("missing credentials")
+
+// APIKey maps the SHA-256 of a secret key to the client it identifies and
+// the scopes it grants. Only the hash is ever held in memory or
+// configuration.
+type APIKey struct {
+    ClientID string
+    Tenant   string
+    Hash     [sha256.Size]byte
+    Scopes   []string
+}
+
+// HashAPIKey returns the hex encoded SHA-256 of key, the form ParseAPIKeys
+// expects.
+func HashAPIKey(key string) string {
+    sum := sha256.Sum256([]byte(key))
+    return hex.EncodeToString(sum[:])
+}
+
+// ParseAPIKeys parses "client=hexsha256,..." as produced by HashAPIKey. A
+// client written as "tenant/client" belongs to that tenant. The hash may be
+// followed by the key's scopes, as in "client=hexsha256;routes:write;admin";
+// a key listing none is granted DefaultScopes.
+func ParseAPIKeys(raw string) ([]APIKey, error) {
+    var keys []APIKey
+    seen := make(map[string]bool)
+    for _, part := range strings.Split(raw, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
+        clientID, value, ok := strings.Cut(part, "=")
+        clientID = strings.TrimSpace(clientID)
+        if !ok || clientID == "" {
+            return nil, fmt.Errorf("%w: %q is not client=hash", ErrInvalidAPIKeys, part)
+        }
+        fields := strings.Split(value, ";")
+        scopes, err := parseScopes(fields[1:])
+        if err != nil {
+            return nil, fmt.Errorf("%w: %s: %v", ErrInvalidAPIKeys, clientID, err)
+        }
+        hash, err := hex.DecodeString(strings.TrimSpace(fields[0]))
+        if err != nil || len(hash) != sha256.Size {
+            return nil, fmt.Errorf("%w: hash for %q is not a hex sha256", ErrInvalidAPIKeys, clientID)
+        }
+        if seen[clientID] {
+            return nil, fmt.Errorf("%w: duplicate client %q", ErrInvalidAPIKeys, clientID)
+        }
+        seen[clientID] = true
+        key := APIKey{ClientID: clientID, Scopes: scopes}
+        if tenant, client, ok := strings.Cut(clientID, "/"); ok {
+            if tenant == "" || client == "" {
+                return nil, fmt.Errorf("%w: %q is not tenant/client", ErrInvalidAPIKeys, clientID)
+            }
+            key.Tenant, key.ClientID = tenant, client
+        }
+        copy(key.Hash[:], hash)
+        keys = append(keys, key)
+    }
+    return keys, nil
+}
+
+// ParseClientCertScopes parses "name=scope;scope,..." into the scopes granted
+// to the client certificates named name, the client ID clientCertIdentity
+// derives from their subject. Certificates not listed are granted
+// DefaultScopes.
+func ParseClientCertScopes(raw string) (map[string][]string, error) {
+    granted := make(map[string][]string)
+    for _, part := range strings.Split(raw, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
+        name, value, ok := strings.Cut(part, "=")
+        name = strings.TrimSpace(name)
+        if !ok || name == "" {
+            return nil, fmt.Errorf("%w: %q is not name=scopes", ErrInvalidClientCertScopes, part)
+        }
+        if _, seen := granted[name]; seen {
+            return nil, fmt.Errorf("%w: duplicate client %q", ErrInvalidClientCertScopes, name)
+        }
+        if strings.TrimSpace(value) == "" {
+            return nil, fmt.Errorf("%w: %q lists no scopes", ErrInvalidClientCertScopes, name)
+        }
+        scopes, err := parseScopes(strings.Split(value, ";"))
+        if err != nil {
+            return nil, fmt.Errorf("%w: %s: %v", ErrInvalidClientCertScopes, name, err)
+        }
+        granted[name] = scopes
+    }
+    return granted, nil
+}
+
+// parseScopes validates a configured scope list, returning DefaultScopes
+// when it is empty.
+func parseScopes(fields []string) ([]string, error) {
+    if len(fields) == 0 {
+        return DefaultScopes, nil
+    }
+    scopes := make([]string, 0, len(fields))
+    for _, field := range fields {
+        scope := strings.TrimSpace(field)
+        if !knownScopes[scope] {
+            return nil, fmt.Errorf("unknown scope %q", scope)
+        }
+        scopes = append(scopes, scope)
+    }
+    return scopes, nil
+}
+
+// WithAPIKeys requires every request except health checks to present one of
+// keys. Without this option or WithJWTVerifier the API is unauthenticated.
+func WithAPIKeys(keys []APIKey) Option {
+    return func(s *Server) {
+        s.apiKeys = keys
+    }
+}
+
+// Scopes enforced per endpoint.
+const (
+    ScopeRoutesWrite = "routes:write"
+    ScopeAuditRead   = "audit:read"
+    ScopeVenuesRead  = "venues:read"
+    ScopeVenuesWrite = "venues:write"
+    ScopeAdmin       = "admin"
+)
+
+// DefaultScopes are granted to API keys and client certificates configured
+// without scopes: every scope but admin.
+var DefaultScopes = []string{ScopeRoutesWrite, ScopeAuditRead, ScopeVenuesRead, ScopeVenuesWrite}
+
+var knownScopes = map[string]bool{
+    ScopeRoutesWrite: true,
+    ScopeAuditRead:   true,
+    ScopeVenuesRead:  true,
+    ScopeVenuesWrite: true,
+    ScopeAdmin:       true,
+}
+
+// WithJWTVerifier accepts "Authorization: Bearer" tokens validated by
+// verifier, alongside any configured API keys.
+func WithJWTVerifier(verifier *jwtauth.Verifier) Option {
+    return func(s *Server) {
+        s.jwtVerifier = verifier
+    }
+}
+
+// WithClientCertAuth accepts verified TLS client certificates as credentials,
+// granting each the scopes listed for it in scopes, as parsed by
+// ParseClientCertScopes. The listener must be configured to request and
+// verify them.
+func WithClientCertAuth(scopes map[string][]string) Option {
+    return func(s *Server) {
+        s.clientCertAuth = true
+        s.clientCertScopes = scopes
+    }
+}
+
+// Identity is the authenticated caller attached to a request's context.
+type Identity struct {
+    // ClientID is the API key's client, the token's subject or the client
+    // certificate's subject.
+    ClientID string
+    // Tenant is the tenant the caller acts for, "" being the default tenant.
+    Tenant string
+    // Method is how the caller authenticated: "api-key", "jwt" or "mtls".
+    Method string
+    Scopes []string
+}
+
+// HasScope reports whether the caller may use endpoints guarded by scope.
+func (i Identity) HasScope(scope string) bool {
+    for _, granted := range i.Scopes {
+        if granted == scope {
+            return true
+        }
+    }
+    return false
+}
+
+type identityKey struct{}
+
+// IdentityFrom returns the caller authenticated for ctx, if any.
+func IdentityFrom(ctx context.Context) (Identity, bool) {
+    identity, ok := ctx.Value(identityKey{}).(Identity)
+    return identity, ok
+}
+
+func withIdentity(ctx context.Context, identity Identity) context.Context {
+    return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// clientID is the name requests are attributed to in logs and the audit log.
+func clientID(ctx context.Context) string {
+    identity, _ := IdentityFrom(ctx)
+    return identity.ClientID
+}
+
+// tenantID is the tenant whose partition a request reads and writes.
+func tenantID(ctx context.Context) string {
+    identity, _ := IdentityFrom(ctx)
+    return identity.Tenant
+}
+
+func (s *Server) withAuth(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if !s.authRequired() || r.URL.Path == "/api/v1/health" {
+            next.ServeHTTP(w, r)
+            return
+        }
+        identity, err := s.authenticate(r)
+        if err != nil {
+            params := `error="invalid_token"`
+            if errors.Is(err, errMissingCredentials) {
+                params = ""
+            }
+            w.Header().Set("WWW-Authenticate", s.authChallenge(params))
+            writeJSON(w, http.StatusUnauthorized, errorResponse{Error: err.Error()})
+            return
+        }
+        next.ServeHTTP(w, r.WithContext(withIdentity(r.Context(), identity)))
+    })
+}
+
+// authRequired reports whether any credentials are configured. Without them
+// the API is open.
+func (s *Server) authRequired() bool {
+    return len(s.apiKeys) > 0 || s.jwtVerifier != nil || s.clientCertAuth
+}
+
+// authenticate accepts a bearer token when JWT validation is configured and
+// an API key otherwise, or as well.
+func (s *Server) authenticate(r *http.Request) (Identity, error) {
+    if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") && s.jwtVerifier != nil {
+        claims, err := s.jwtVerifier.Verify(r.Context(), strings.TrimSpace(token), time.Now())
+        if err != nil {
+            return Identity{}, errors.New("invalid bearer token")
+        }
+        return Identity{ClientID: claims.Subject, Tenant: claims.Tenant, Method: "jwt", Scopes: claims.Scopes}, nil
+    }
+    presented := r.Header.Get(APIKeyHeader)
+    if presented == "" || len(s.apiKeys) == 0 {
+        if identity, ok := s.clientCertIdentity(r); ok {
+            return identity, nil
+        }
+        return Identity{}, errMissingCredentials
+    }
+    identity, ok := s.lookupAPIKey(presented)
+    if !ok {
+        return Identity{}, errors.New("invalid api key")
+    }
+    return identity, nil
+}
+
+// clientCertIdentity names the caller after the subject of a client
+// certificate the TLS handshake verified: its common name, or the full
+// distinguished name when there is none. The subject's organization is the
+// caller's tenant. Its scopes are those configured for that name.
+func (s *Server) clientCertIdentity(r *http.Request) (Identity, bool) {
+    if !s.clientCertAuth || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+        return Identity{}, false
+    }
+    subject := r.TLS.VerifiedChains[0][0].Subject
+    name := subject.CommonName
+    if name == "" {
+        name = subject.String()
+    }
+    scopes, ok := s.clientCertScopes[name]
+    if !ok {
+        scopes = DefaultScopes
+    }
+    identity := Identity{ClientID: name, Method: "mtls", Scopes: scopes}
+    if len(subject.Organization) > 0 {
+        identity.Tenant = subject.Organization[0]
+    }
+    return identity, true
+}
+
+func (s *Server) authChallenge(params string) string {
+    if s.jwtVerifier == nil {
+        return "ApiKey"
+    }
+    return strings.TrimSpace("Bearer " + params)
+}
+
+// requireScope rejects authenticated callers whose token lacks scope. It is
+// a no-op when authentication is disabled.
+func (s *Server) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if s.denyScope(w, r, scope) {
+            return
+        }
+        next(w, r)
+    }
+}
+
+// requireReadWriteScope is requireScope for resources that are read with GET
+// and changed with any other method.
+func (s *Server) requireReadWriteScope(read, write string, next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        scope := write
+        if r.Method == http.MethodGet || r.Method == http.MethodHead {
+            scope = read
+        }
+        if s.denyScope(w, r, scope) {
+            return
+        }
+        next(w, r)
+    }
+}
+
+func (s *Server) denyScope(w http.ResponseWriter, r *http.Request, scope string) bool {
+    identity, ok := IdentityFrom(r.Context())
+    if !ok || identity.HasScope(scope) {
+        return false
+    }
+    w.Header().Set("WWW-Authenticate", s.authChallenge(`error="insufficient_scope", scope="`+scope+`"`))
+    writeJSON(w, http.StatusForbidden, errorResponse{Error: "missing scope " + scope})
+    return true
+}
+
+// lookupAPIKey compares the presented key's hash against every configured
+// hash in constant time.
+func (s *Server) lookupAPIKey(presented string) (Identity, bool) {
+    sum := sha256.Sum256([]byte(presented))
+    var match Identity
+    found := false
+    for _, key := range s.apiKeys {
+        if subtle.ConstantTimeCompare(sum[:], key.Hash[:]) == 1 {
+            match = Identity{ClientID: key.ClientID, Tenant: key.Tenant, Method: "api-key", Scopes: key.Scopes}
+            found = true
+        }
+    }
+    return match, found
+}
@@ -0,0 +1,223 @@
+package httpapi
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/jwtauth"
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/ratelimit"
+)
+
+func getVenues(handler http.Handler, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/venues", nil)
+	r.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+	return recorder
+}
+
+// apiKeyServer allows each client requestsPerMin and each address
+// addressPerMin.
+func apiKeyServer(t *testing.T, requestsPerMin, addressPerMin int) http.Handler {
+	t.Helper()
+	keys, err := ParseAPIKeys("acme/desk=" + HashAPIKey("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := ratelimit.NewFixedWindow(ratelimit.Config{MaxRequests: requestsPerMin, Window: time.Minute})
+	addressLimiter := ratelimit.NewFixedWindow(ratelimit.Config{MaxRequests: addressPerMin, Window: time.Minute})
+	return NewServer(limiter, WithAPIKeys(keys), WithAddressRateLimit(addressLimiter)).Handler()
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	handler := apiKeyServer(t, 100, 100)
+
+	missing := getVenues(handler, "192.0.2.1:1000", nil)
+	if missing.Code != http.StatusUnauthorized || missing.Header().Get("WWW-Authenticate") != "ApiKey" {
+		t.Fatalf("expected a 401 ApiKey challenge, got %d %q", missing.Code, missing.Header().Get("WWW-Authenticate"))
+	}
+	if wrong := getVenues(handler, "192.0.2.1:1000", map[string]string{APIKeyHeader: "guess"}); wrong.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a wrong key to be rejected, got %d", wrong.Code)
+	}
+	if ok := getVenues(handler, "192.0.2.1:1000", map[string]string{APIKeyHeader: "secret"}); ok.Code != http.StatusOK {
+		t.Fatalf("expected a valid key to be accepted, got %d %s", ok.Code, ok.Body)
+	}
+
+	health := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, health)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected health checks to skip authentication, got %d", recorder.Code)
+	}
+}
+
+func TestFailedAuthenticationIsRateLimitedByAddress(t *testing.T) {
+	handler := apiKeyServer(t, 100, 3)
+
+	for i := 0; i < 3; i++ {
+		if response := getVenues(handler, "192.0.2.1:1000", map[string]string{APIKeyHeader: "guess"}); response.Code != http.StatusUnauthorized {
+			t.Fatalf("guess %d: expected 401, got %d", i, response.Code)
+		}
+	}
+	limited := getVenues(handler, "192.0.2.1:1000", map[string]string{APIKeyHeader: "guess"})
+	if limited.Code != http.StatusTooManyRequests || limited.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected guessing to be rate limited before authentication, got %d", limited.Code)
+	}
+	if other := getVenues(handler, "192.0.2.2:1000", map[string]string{APIKeyHeader: "secret"}); other.Code != http.StatusOK {
+		t.Fatalf("expected other addresses to keep their quota, got %d", other.Code)
+	}
+}
+
+func TestAuthenticatedClientsKeepTheirQuotaAcrossAddresses(t *testing.T) {
+	handler := apiKeyServer(t, 2, 100)
+
+	for i, addr := range []string{"192.0.2.1:1000", "192.0.2.2:1000"} {
+		if response := getVenues(handler, addr, map[string]string{APIKeyHeader: "secret"}); response.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, response.Code)
+		}
+	}
+	if response := getVenues(handler, "192.0.2.3:1000", map[string]string{APIKeyHeader: "secret"}); response.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the client quota to follow the client, got %d", response.Code)
+	}
+}
+
+func TestClientsBehindOneAddressKeepTheirOwnQuota(t *testing.T) {
+	keys, err := ParseAPIKeys("acme/desk=" + HashAPIKey("secret") + ",acme/ops=" + HashAPIKey("ops-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := ratelimit.NewFixedWindow(ratelimit.Config{MaxRequests: 2, Window: time.Minute})
+	handler := NewServer(limiter, WithAPIKeys(keys)).Handler()
+
+	for _, key := range []string{"secret", "secret", "ops-secret", "ops-secret"} {
+		if response := getVenues(handler, "192.0.2.1:1000", map[string]string{APIKeyHeader: key}); response.Code != http.StatusOK {
+			t.Fatalf("expected each client its own quota behind a shared address, got %d", response.Code)
+		}
+	}
+	if response := getVenues(handler, "192.0.2.1:1000", map[string]string{APIKeyHeader: "secret"}); response.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the client quota to still apply, got %d", response.Code)
+	}
+}
+
+func TestBearerTokenScopes(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "k1",
+		"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+	}}})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := jwtauth.NewVerifier(context.Background(), jwtauth.Config{JWKS: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := ratelimit.NewFixedWindow(ratelimit.Config{MaxRequests: 100, Window: time.Minute})
+	handler := NewServer(limiter, WithJWTVerifier(verifier)).Handler()
+
+	sign := func(scope string) string {
+		encode := func(v any) string {
+			data, _ := json.Marshal(v)
+			return base64.RawURLEncoding.EncodeToString(data)
+		}
+		input := encode(map[string]string{"alg": "RS256", "kid": "k1"}) + "." +
+			encode(map[string]any{"sub": "desk", "scope": scope, "exp": time.Now().Add(time.Hour).Unix()})
+		digest := sha256.Sum256([]byte(input))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + input + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+
+	if response := getVenues(handler, "192.0.2.1:1000", map[string]string{"Authorization": sign(ScopeVenuesRead)}); response.Code != http.StatusOK {
+		t.Fatalf("expected venues:read to list venues, got %d %s", response.Code, response.Body)
+	}
+	denied := getVenues(handler, "192.0.2.1:1000", map[string]string{"Authorization": sign(ScopeAuditRead)})
+	if denied.Code != http.StatusForbidden || denied.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("expected a missing scope to be refused, got %d", denied.Code)
+	}
+	invalid := getVenues(handler, "192.0.2.1:1000", map[string]string{"Authorization": sign(ScopeVenuesRead) + "x"})
+	if invalid.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a tampered token to be rejected, got %d", invalid.Code)
+	}
+}
+
+func TestAPIKeysAndCertificatesDefaultToNonAdminScopes(t *testing.T) {
+	keys, err := ParseAPIKeys("desk=" + HashAPIKey("desk-key") + ",ops=" + HashAPIKey("ops-key") + ";admin;venues:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certScopes, err := ParseClientCertScopes("ops-cert=admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := ratelimit.NewFixedWindow(ratelimit.Config{MaxRequests: 100, Window: time.Minute})
+	handler := NewServer(limiter, WithAPIKeys(keys), WithClientCertAuth(certScopes)).Handler()
+
+	admin := func(configure func(*http.Request)) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/admin/metrics/targets", nil)
+		configure(r)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		return recorder.Code
+	}
+	withKey := func(key string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set(APIKeyHeader, key) }
+	}
+	withCert := func(name string) func(*http.Request) {
+		return func(r *http.Request) {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: name}}
+			r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+	}
+
+	for name, c := range map[string]struct {
+		configure func(*http.Request)
+		want      int
+	}{
+		"key without scopes":         {withKey("desk-key"), http.StatusForbidden},
+		"key granted admin":          {withKey("ops-key"), http.StatusOK},
+		"certificate without scopes": {withCert("desk-cert"), http.StatusForbidden},
+		"certificate granted admin":  {withCert("ops-cert"), http.StatusOK},
+	} {
+		if got := admin(c.configure); got != c.want {
+			t.Errorf("%s: expected %d from an admin endpoint, got %d", name, c.want, got)
+		}
+	}
+	if ok := getVenues(handler, "192.0.2.1:1000", map[string]string{APIKeyHeader: "ops-key"}); ok.Code != http.StatusOK {
+		t.Fatalf("expected listed scopes to be granted, got %d", ok.Code)
+	}
+
+	if _, err := ParseAPIKeys("desk=" + HashAPIKey("k") + ";root"); !errors.Is(err, ErrInvalidAPIKeys) {
+		t.Fatalf("expected an unknown scope to be rejected, got %v", err)
+	}
+	if _, err := ParseClientCertScopes("ops-cert="); !errors.Is(err, ErrInvalidClientCertScopes) {
+		t.Fatalf("expected an empty scope list to be rejected, got %v", err)
+	}
+}
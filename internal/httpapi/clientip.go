@@ -0,0 +1,188 @@
+package httpapi
+
+import (
+    "fmt"
+    "net"
+    "net/http"
+    "net/netip"
+    "strings"
+)
+
+// ParseTrustedProxies parses a comma separated list of CIDRs or bare IPs,
+// e.g. "10.0.0.0/8,192.168.1.10".
+func ParseTrustedProxies(raw string) ([]netip.Prefix, error) {
+    var prefixes []netip.Prefix
+    for _, part := range strings.Split(raw, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
+        if strings.Contains(part, "/") {
+            prefix, err := netip.ParsePrefix(part)
+            if err != nil {
+                return nil, fmt.Errorf("invalid trusted proxy %q: %w", part, err)
+            }
+            prefixes = append(prefixes, prefix.Masked())
+            continue
+        }
+        addr, err := netip.ParseAddr(part)
+        if err != nil {
+            return nil, fmt.Errorf("invalid trusted proxy %q: %w", part, err)
+        }
+        addr = addr.Unmap()
+        prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+    }
+    return prefixes, nil
+}
+
+// Forwarding headers a trusted proxy can report the client address in.
+const (
+    ProxyHeaderForwarded     = "forwarded"
+    ProxyHeaderXForwardedFor = "x-forwarded-for"
+)
+
+// ParseTrustedProxyHeader validates the name of the forwarding header trusted
+// proxies set, defaulting to X-Forwarded-For.
+func ParseTrustedProxyHeader(raw string) (string, error) {
+    header := strings.ToLower(strings.TrimSpace(raw))
+    switch header {
+    case "":
+        return ProxyHeaderXForwardedFor, nil
+    case ProxyHeaderForwarded, ProxyHeaderXForwardedFor:
+        return header, nil
+    }
+    return "", fmt.Errorf("invalid trusted proxy header %q: want %s or %s", raw, ProxyHeaderForwarded, ProxyHeaderXForwardedFor)
+}
+
+// WithTrustedProxies lists the proxies whose forwarding headers are believed.
+// Requests arriving from any other address are keyed by their peer address.
+func WithTrustedProxies(prefixes []netip.Prefix) Option {
+    return func(s *Server) {
+        s.trustedProxies = prefixes
+    }
+}
+
+// WithTrustedProxyHeader selects the one forwarding header the trusted proxies
+// append to, ProxyHeaderForwarded or ProxyHeaderXForwardedFor. Any other
+// forwarding header is client controlled and ignored.
+func WithTrustedProxyHeader(header string) Option {
+    return func(s *Server) {
+        s.trustedProxyHeader = header
+    }
+}
+
+// clientIP resolves the address requests are attributed to. Only the
+// configured forwarding header is consulted, only when the peer is a trusted
+// proxy, and it is walked right to left, skipping trusted hops, so a client
+// cannot choose its own address by prepending values.
+func (s *Server) clientIP(r *http.Request) string {
+    peer := remoteHost(r.RemoteAddr)
+    if !s.trusted(peer) {
+        return peer
+    }
+
+    var hops []string
+    if s.trustedProxyHeader == ProxyHeaderForwarded {
+        hops = forwardedFor(r.Header.Values("Forwarded"))
+    } else {
+        hops = xForwardedFor(r.Header.Values("X-Forwarded-For"))
+    }
+    for i := len(hops) - 1; i >= 0; i-- {
+        if !s.trusted(hops[i]) {
+            return hops[i]
+        }
+    }
+    if len(hops) > 0 {
+        return hops[0]
+    }
+    return peer
+}
+
+func (s *Server) trusted(host string) bool {
+    addr, err := netip.ParseAddr(host)
+    if err != nil {
+        return false
+    }
+    addr = addr.Unmap()
+    for _, prefix := range s.trustedProxies {
+        if prefix.Contains(addr) {
+            return true
+        }
+    }
+    return false
+}
+
+func remoteHost(remoteAddr string) string {
+    host, _, err := net.SplitHostPort(remoteAddr)
+    if err != nil {
+        return remoteAddr
+    }
+    return host
+}
+
+func xForwardedFor(values []string) []string {
+    var hops []string
+    for _, value := range values {
+        for _, hop := range strings.Split(value, ",") {
+            if hop = strings.TrimSpace(hop); hop != "" {
+                hops = append(hops, hop)
+            }
+        }
+    }
+    return hops
+}
+
+// forwardedFor extracts the for= node of every element of RFC 7239 Forwarded
+// headers, in order, with ports and IPv6 brackets stripped. Obfuscated and
+// "unknown" nodes are kept verbatim.
+func forwardedFor(values []string) []string {
+    var hops []string
+    for _, value := range values {
+        for _, element := range splitQuoted(value, ',') {
+            for _, pair := range splitQuoted(element, ';') {
+                key, node, ok := strings.Cut(strings.TrimSpace(pair), "=")
+                if !ok || !strings.EqualFold(strings.TrimSpace(key), "for") {
+                    continue
+                }
+                node = strings.Trim(strings.TrimSpace(node), `"`)
+                if node != "" {
+                    hops = append(hops, stripNodePort(node))
+                }
+            }
+        }
+    }
+    return hops
+}
+
+func stripNodePort(node string) string {
+    if strings.HasPrefix(node, "[") {
+        if end := strings.Index(node, "]"); end > 0 {
+            return node[1:end]
+        }
+        return node
+    }
+    if strings.Count(node, ":") == 1 {
+        host, _, _ := strings.Cut(node, ":")
+        return host
+    }
+    return node
+}
+
+// splitQuoted splits s on sep outside of double quoted strings.
+func splitQuoted(s string, sep byte) []string {
+    var parts []string
+    quoted := false
+    start := 0
+    for i := 0; i < len(s); i++ {
+        switch {
+        case s[i] == '\\' && quoted:
+            i++
+        case s[i] == '"':
+            quoted = !quoted
+        case s[i] == sep && !quoted:
+            parts = append(parts, s[start:i])
+            start = i + 1
+        }
+    }
+    return append(parts, s[start:])
+}
@@ -0,0 +1,95 @@
+package httpapi
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPWalksOnlyTheConfiguredHeader(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		header  string
+		peer    string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:    "untrusted peer ignores headers",
+			header:  ProxyHeaderXForwardedFor,
+			peer:    "203.0.113.9:4000",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "rightmost untrusted hop wins over a prepended one",
+			header:  ProxyHeaderXForwardedFor,
+			peer:    "10.0.0.2:4000",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 10.0.0.3"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:   "forged Forwarded is ignored when proxies append X-Forwarded-For",
+			header: ProxyHeaderXForwardedFor,
+			peer:   "10.0.0.2:4000",
+			headers: map[string]string{
+				"Forwarded":       "for=1.2.3.4",
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:   "forged X-Forwarded-For is ignored when proxies append Forwarded",
+			header: ProxyHeaderForwarded,
+			peer:   "10.0.0.2:4000",
+			headers: map[string]string{
+				"Forwarded":       `for=1.2.3.4, for="[2001:db8::1]:443";proto=https`,
+				"X-Forwarded-For": "5.6.7.8",
+			},
+			want: "2001:db8::1",
+		},
+		{
+			name:    "X-Real-IP is never trusted",
+			header:  ProxyHeaderXForwardedFor,
+			peer:    "10.0.0.2:4000",
+			headers: map[string]string{"X-Real-IP": "1.2.3.4"},
+			want:    "10.0.0.2",
+		},
+		{
+			name:    "all hops trusted falls back to the leftmost",
+			header:  ProxyHeaderXForwardedFor,
+			peer:    "10.0.0.2:4000",
+			headers: map[string]string{"X-Forwarded-For": "10.0.0.5, 10.0.0.3"},
+			want:    "10.0.0.5",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &Server{trustedProxies: proxies, trustedProxyHeader: tc.header}
+			request := httptest.NewRequest("GET", "/api/v1/health", nil)
+			request.RemoteAddr = tc.peer
+			for name, value := range tc.headers {
+				request.Header.Set(name, value)
+			}
+			if got := server.clientIP(request); got != tc.want {
+				t.Fatalf("clientIP = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxyHeader(t *testing.T) {
+	if header, err := ParseTrustedProxyHeader(""); err != nil || header != ProxyHeaderXForwardedFor {
+		t.Fatalf("default = %q, %v", header, err)
+	}
+	if header, err := ParseTrustedProxyHeader("Forwarded"); err != nil || header != ProxyHeaderForwarded {
+		t.Fatalf("Forwarded = %q, %v", header, err)
+	}
+	if _, err := ParseTrustedProxyHeader("x-real-ip"); err == nil {
+		t.Fatalf("x-real-ip should be rejected")
+	}
+}
@@ -7,9 +7,9 @@ import (
     "encoding/json"
     "errors"
     "io"
-    "net"
     "net/http"
     "os"
+    "strconv"
     "strings"
     "sync"
     "time"
@@ -98,20 +98,95 @@ func (s *Server) handleRoutes(w http.ResponseWriter, r *http.Request) {
     if strings.TrimSpace(routeID) == "" {
         routeID = newID()
     }
-
-    targets := payload.TargetsToRouting()
-    if s.metricCache != nil {
-        targets = s.metricCache.Merge(targets, time.Now().UTC())
+    if !s.checkRisk(ctx, w, r, routeID, payload) {
+        return
     }
 
-    decision, err := routing.SelectTarget(targets)
+    strategy, err := s.resolveStrategy(payload)
     if err != nil {
-        status := http.StatusInternalServerError
-        message := "routing decision failed"
-        if errors.Is(err, routing.ErrNoTargets) {
-            status = http.StatusBadRequest
-            message = "no targets provided"
+        writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+        logRequest(ctx, logEntry{
+            Message:     err.Error(),
+            RouteID:     routeID,
+            Destination: "",
+            Status:      http.StatusBadRequest,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        return
+    }
+
+    tenant := tenantFrom(ctx)
+    candidates := payload.TargetsToRouting()
+    registered := len(payload.Targets) == 0
+    if registered {
+        candidates, err = s.registryTargets(tenant, payload.Venues)
+        if err != nil {
+            status, message := http.StatusInternalServerError, "failed to read venues"
+            var unknown errUnknownVenue
+            if errors.As(err, &unknown) {
+                status, message = http.StatusBadRequest, unknown.Error()
+            }
+            writeJSON(w, status, errorResponse{Error: message})
+            logRequest(ctx, logEntry{
+                Message:     message,
+                RouteID:     routeID,
+                Destination: "",
+                Status:      status,
+                Path:        r.URL.Path,
+                Method:      r.Method,
+            })
+            return
+        }
+    }
+    for _, target := range candidates {
+        if !tenant.tenant.AllowsVenue(target.ID) {
+            message := "target " + target.ID + " is not permitted for this tenant"
+            writeJSON(w, http.StatusForbidden, errorResponse{Error: message})
+            logRequest(ctx, logEntry{
+                Message:     message,
+                RouteID:     routeID,
+                Destination: target.ID,
+                Status:      http.StatusForbidden,
+                Path:        r.URL.Path,
+                Method:      r.Method,
+            })
+            return
         }
+    }
+
+    // Metrics a client sends are an observation; a registered venue's are
+    // the operator's baseline, which only stands in until something is
+    // measured.
+    var targets []routing.Target
+    if registered {
+        targets = tenant.metricCache.Apply(candidates, time.Now().UTC())
+    } else {
+        targets = tenant.metricCache.Merge(candidates, time.Now().UTC())
+    }
+
+    explain := parseBool(r.URL.Query().Get("explain"))
+    if explain && payload.Split {
+        writeJSON(w, http.StatusBadRequest, errorResponse{Error: "explain is not supported for split orders"})
+        logRequest(ctx, logEntry{
+            Message:     "explain is not supported for split orders",
+            RouteID:     routeID,
+            Destination: "",
+            Status:      http.StatusBadRequest,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        return
+    }
+
+    if payload.Split {
+        s.routeSplit(ctx, w, r, start, routeID, payload, targets, strategy)
+        return
+    }
+
+    decision, err := routing.Select(targets, routing.Options{Strategy: strategy, Breakers: tenant.breakers})
+    if err != nil {
+        status, message := routingErrorStatus(err)
         writeJSON(w, status, errorResponse{Error: message})
         logRequest(ctx, logEntry{
             Message:     message,
@@ -128,18 +203,25 @@ func (s *Server) handleRoutes(w http.ResponseWriter, r *http.Request) {
         attribute.String("route.id", routeID),
         attribute.String("routing.target", decision.Target.ID),
         attribute.Bool("routing.fallback", decision.Fallback),
+        attribute.String("routing.strategy", decision.Strategy),
     )
 
     response := routeResponse{
         RouteID: routeID,
         TraceID: span.SpanContext().TraceID().String(),
         Decision: decisionPayload{
-            TargetID: decision.Target.ID,
-            Reason:   decision.Reason,
-            Fallback: decision.Fallback,
-            Score:    decision.Score,
+            TargetID:  decision.Target.ID,
+            Reason:    decision.Reason,
+            Fallback:  decision.Fallback,
+            Score:     decision.Score,
+            Strategy:  decision.Strategy,
+            Breakdown: newBreakdownPayload(decision.Breakdown),
         },
     }
+    if explain {
+        response.Decision.DecidedBy = decision.DecidedBy
+        response.Candidates = newCandidatePayloads(decision.Candidates)
+    }
 
     writeJSON(w, http.StatusOK, response)
 
@@ -158,18 +240,314 @@ func (s *Server) handleRoutes(w http.ResponseWriter, r *http.Request) {
         Fallback:       decision.Fallback,
     })
 
-    if s.auditStore != nil {
-        s.auditStore.Add(audit.Entry{
-            Timestamp:   time.Now().UTC(),
+    s.recordAudit(ctx, r, audit.Entry{
+        Timestamp:   time.Now().UTC(),
+        RouteID:     routeID,
+        OrderID:     payload.Order.ID,
+        TargetID:    decision.Target.ID,
+        Quantity:    payload.Order.Quantity,
+        Reason:      decision.Reason,
+        Fallback:    decision.Fallback,
+        Score:       decision.Score,
+        Strategy:    decision.Strategy,
+        TargetCount: len(targets),
+    })
+}
+
+// routeSplit answers a split request with one child order per allocated
+// target. Each child gets its own route ID, derived from the parent, and its
+// own audit entry.
+func (s *Server) routeSplit(ctx context.Context, w http.ResponseWriter, r *http.Request, start time.Time, routeID string, payload routeRequest, targets []routing.Target, strategy routing.Strategy) {
+    span := trace.SpanFromContext(ctx)
+
+    split, err := routing.Split(targets, payload.Order.Quantity, routing.Options{Strategy: strategy, Breakers: tenantFrom(ctx).breakers})
+    if err != nil {
+        status, message := routingErrorStatus(err)
+        writeJSON(w, status, errorResponse{Error: message})
+        logRequest(ctx, logEntry{
+            Message:     message,
+            RouteID:     routeID,
+            Destination: "",
+            Status:      status,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        return
+    }
+
+    children := make([]childPayload, 0, len(split.Children))
+    destinations := make([]string, 0, len(split.Children))
+    for idx, child := range split.Children {
+        children = append(children, childPayload{
+            RouteID:  routeID + "-" + strconv.Itoa(idx+1),
+            TargetID: child.Target.ID,
+            Quantity: child.Quantity,
+            Reason:   child.Reason,
+            Score:    child.Score,
+        })
+        destinations = append(destinations, child.Target.ID)
+    }
+
+    span.SetAttributes(
+        attribute.String("route.id", routeID),
+        attribute.StringSlice("routing.targets", destinations),
+        attribute.Bool("routing.fallback", split.Fallback),
+        attribute.String("routing.strategy", split.Strategy),
+    )
+
+    writeJSON(w, http.StatusOK, splitRouteResponse{
+        RouteID:  routeID,
+        TraceID:  span.SpanContext().TraceID().String(),
+        Strategy: split.Strategy,
+        Fallback: split.Fallback,
+        Children: children,
+    })
+
+    durationMs := time.Since(start).Milliseconds()
+    recordMetrics(ctx, r.URL.Path, durationMs, split.Fallback)
+
+    logRequest(ctx, logEntry{
+        Message:        "split routing decision",
+        RouteID:        routeID,
+        Destination:    strings.Join(destinations, ","),
+        Status:         http.StatusOK,
+        Path:           r.URL.Path,
+        Method:         r.Method,
+        DurationMs:     durationMs,
+        BudgetExceeded: durationMs > latencyBudgetMs,
+        Fallback:       split.Fallback,
+    })
+
+    now := time.Now().UTC()
+    for idx, child := range split.Children {
+        s.recordAudit(ctx, r, audit.Entry{
+            Timestamp:     now,
+            RouteID:       children[idx].RouteID,
+            ParentRouteID: routeID,
+            OrderID:       payload.Order.ID,
+            TargetID:      child.Target.ID,
+            Quantity:      child.Quantity,
+            Reason:        child.Reason,
+            Fallback:      split.Fallback,
+            Score:         child.Score,
+            Strategy:      split.Strategy,
+            TargetCount:   len(targets),
+        })
+    }
+}
+
+func routingErrorStatus(err error) (int, string) {
+    switch {
+    case errors.Is(err, routing.ErrNoTargets):
+        return http.StatusBadRequest, "no targets provided"
+    case errors.Is(err, routing.ErrInsufficientCapacity):
+        return http.StatusUnprocessableEntity, err.Error()
+    case errors.Is(err, routing.ErrAllCircuitsOpen):
+        return http.StatusServiceUnavailable, err.Error()
+    }
+    return http.StatusInternalServerError, "routing decision failed"
+}
+
+// resolveStrategy picks the strategy named in the request. Request weights
+// build a one-off weighted-score strategy and imply it when no strategy is
+// named.
+func (s *Server) resolveStrategy(payload routeRequest) (routing.Strategy, error) {
+    name := strings.TrimSpace(payload.Strategy)
+    if payload.Weights != nil {
+        if name != "" && name != "weighted-score" {
+            return nil, errors.New("weights are only supported by the weighted-score strategy")
+        }
+        return routing.NewWeightedScore(payload.Weights.toRouting()), nil
+    }
+    strategy, err := s.strategies.Lookup(name)
+    if err != nil {
+        return nil, errors.New("unknown strategy")
+    }
+    return strategy, nil
+}
+
+// handleRouteOutcome records how an order routed to a target ended up. The
+// outcome feeds the target's circuit breaker and cached metrics, and is
+// appended to the audit trail next to the route's decision.
+func (s *Server) handleRouteOutcome(w http.ResponseWriter, r *http.Request) {
+    ctx, span := startSpan(r.Context(), r)
+    defer span.End()
+
+    routeID := r.PathValue("routeId")
+
+    if r.Method != http.MethodPost {
+        writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+        logRequest(ctx, logEntry{
+            Message:     "method not allowed",
+            RouteID:     routeID,
+            Destination: "",
+            Status:      http.StatusMethodNotAllowed,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        return
+    }
+
+    var payload outcomeRequest
+    if err := readJSON(r, &payload); err != nil {
+        writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+        logRequest(ctx, logEntry{
+            Message:     "invalid request",
+            RouteID:     routeID,
+            Destination: "",
+            Status:      http.StatusBadRequest,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        return
+    }
+
+    if err := payload.Validate(); err != nil {
+        writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+        logRequest(ctx, logEntry{
+            Message:     "validation failed",
+            RouteID:     routeID,
+            Destination: payload.TargetID,
+            Status:      http.StatusBadRequest,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        return
+    }
+
+    // Each route takes one outcome, so replaying a report cannot trip the
+    // target's breaker or skew its metrics for the rest of the tenant.
+    s.outcomeMu.Lock()
+    defer s.outcomeMu.Unlock()
+
+    tenant := tenantFrom(ctx)
+    decision, found, err := s.auditStore.Find(tenant.tenant.ID, routeID)
+    if err != nil {
+        writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "audit lookup failed"})
+        logRequest(ctx, logEntry{
+            Message:     "audit lookup failed: " + err.Error(),
+            RouteID:     routeID,
+            Destination: payload.TargetID,
+            Status:      http.StatusInternalServerError,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        return
+    }
+    // Only the tenant's own routes are found, so another tenant's route is
+    // reported as missing and route IDs do not leak across tenants.
+    if !found {
+        writeJSON(w, http.StatusNotFound, errorResponse{Error: "route not found"})
+        logRequest(ctx, logEntry{
+            Message:     "route not found",
+            RouteID:     routeID,
+            Destination: payload.TargetID,
+            Status:      http.StatusNotFound,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        return
+    }
+    if payload.TargetID == "" {
+        payload.TargetID = decision.TargetID
+    }
+    if payload.TargetID != decision.TargetID {
+        writeJSON(w, http.StatusConflict, errorResponse{Error: "targetId does not match the routed target"})
+        logRequest(ctx, logEntry{
+            Message:     "outcome target mismatch",
+            RouteID:     routeID,
+            Destination: payload.TargetID,
+            Status:      http.StatusConflict,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        return
+    }
+
+    _, reported, err := s.auditStore.FindOutcome(tenant.tenant.ID, routeID)
+    if err != nil {
+        writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "audit lookup failed"})
+        logRequest(ctx, logEntry{
+            Message:     "audit lookup failed: " + err.Error(),
+            RouteID:     routeID,
+            Destination: payload.TargetID,
+            Status:      http.StatusInternalServerError,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        return
+    }
+    if reported {
+        writeJSON(w, http.StatusConflict, errorResponse{Error: "outcome already recorded"})
+        logRequest(ctx, logEntry{
+            Message:     "outcome already recorded",
+            RouteID:     routeID,
+            Destination: payload.TargetID,
+            Status:      http.StatusConflict,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        return
+    }
+
+    now := time.Now().UTC()
+    err = s.auditStore.Add(audit.Entry{
+        Timestamp:     now,
+        RouteID:       routeID,
+        ParentRouteID: decision.ParentRouteID,
+        OrderID:       decision.OrderID,
+        TargetID:      decision.TargetID,
+        Quantity:      decision.Quantity,
+        Reason:        decision.Reason,
+        Fallback:      decision.Fallback,
+        Score:         decision.Score,
+        Strategy:      decision.Strategy,
+        TargetCount:   decision.TargetCount,
+        ClientID:      clientID(ctx),
+        Tenant:        tenant.tenant.ID,
+        Outcome: &audit.Outcome{
+            Status:    payload.Status,
+            LatencyMs: payload.LatencyMs,
+        },
+    })
+    if err != nil {
+        writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to record outcome"})
+        logRequest(ctx, logEntry{
+            Message:     "audit write failed: " + err.Error(),
             RouteID:     routeID,
-            OrderID:     payload.Order.ID,
-            TargetID:    decision.Target.ID,
-            Reason:      decision.Reason,
-            Fallback:    decision.Fallback,
-            Score:       decision.Score,
-            TargetCount: len(targets),
+            Destination: payload.TargetID,
+            Status:      http.StatusInternalServerError,
+            Path:        r.URL.Path,
+            Method:      r.Method,
         })
+        return
     }
+
+    state := tenant.breakers.Record(payload.TargetID, payload.Succeeded(), now)
+    tenant.metricCache.Observe(payload.TargetID, payload.ObservedLatency(), payload.Reachable(), now)
+
+    span.SetAttributes(
+        attribute.String("route.id", routeID),
+        attribute.String("routing.target", payload.TargetID),
+        attribute.String("outcome.status", payload.Status),
+        attribute.String("circuit.state", string(state)),
+    )
+
+    writeJSON(w, http.StatusAccepted, outcomeResponse{
+        RouteID:  routeID,
+        OrderID:  decision.OrderID,
+        TargetID: payload.TargetID,
+        Status:   payload.Status,
+        Circuit:  string(state),
+    })
+    logRequest(ctx, logEntry{
+        Message:     "route outcome",
+        RouteID:     routeID,
+        Destination: payload.TargetID,
+        Status:      http.StatusAccepted,
+        Path:        r.URL.Path,
+        Method:      r.Method,
+    })
 }
 
 func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
@@ -181,8 +559,66 @@ func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
         writeJSON(w, http.StatusOK, auditResponse{Entries: []audit.Entry{}})
         return
     }
-    limit := parseLimit(r.URL.Query().Get("limit"), 50)
-    writeJSON(w, http.StatusOK, auditResponse{Entries: s.auditStore.List(limit)})
+    query, err := parseAuditQuery(r.URL.Query())
+    if err != nil {
+        writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+        return
+    }
+    query.Tenant = &tenantFrom(r.Context()).tenant.ID
+    page, err := s.auditStore.Query(query)
+    if errors.Is(err, audit.ErrInvalidCursor) {
+        writeJSON(w, http.StatusBadRequest, errorResponse{Error: "cursor is invalid"})
+        return
+    }
+    if err != nil {
+        writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to read audit log"})
+        return
+    }
+    writeJSON(w, http.StatusOK, auditResponse{Entries: page.Entries, NextCursor: encodeCursor(page.NextCursor)})
+}
+
+// handleAuditVerify walks the audit hash chain and reports the first broken
+// link, if any. The chain spans every tenant, so only the default tenant, the
+// operator's, may verify it.
+func (s *Server) handleAuditVerify(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+        return
+    }
+    if tenantFrom(r.Context()).tenant.ID != "" {
+        writeJSON(w, http.StatusForbidden, errorResponse{Error: "audit verification is restricted to the default tenant"})
+        return
+    }
+    if s.auditStore == nil {
+        writeJSON(w, http.StatusOK, audit.VerifyReport{Valid: true})
+        return
+    }
+    report, err := audit.Verify(s.auditStore)
+    if err != nil {
+        writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to read audit log"})
+        return
+    }
+    writeJSON(w, http.StatusOK, report)
+}
+
+// recordAudit appends entry to the audit store. The response has already
+// been sent by the time decisions are audited, so failures are logged.
+func (s *Server) recordAudit(ctx context.Context, r *http.Request, entry audit.Entry) {
+    if s.auditStore == nil {
+        return
+    }
+    entry.ClientID = clientID(ctx)
+    entry.Tenant = tenantFrom(ctx).tenant.ID
+    if err := s.auditStore.Add(entry); err != nil {
+        logRequest(ctx, logEntry{
+            Message:     "audit write failed: " + err.Error(),
+            RouteID:     entry.RouteID,
+            Destination: entry.TargetID,
+            Status:      http.StatusInternalServerError,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+    }
 }
 
 func readJSON(r *http.Request, dst any) error {
@@ -203,21 +639,6 @@ func writeJSON(w http.ResponseWriter, status int, payload any) {
     _ = json.NewEncoder(w).Encode(payload)
 }
 
-func clientIP(r *http.Request) string {
-    if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-        parts := strings.Split(forwarded, ",")
-        return strings.TrimSpace(parts[0])
-    }
-    if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
-        return strings.TrimSpace(realIP)
-    }
-    host, _, err := net.SplitHostPort(r.RemoteAddr)
-    if err == nil {
-        return host
-    }
-    return r.RemoteAddr
-}
-
 func newID() string {
     bytes := make([]byte, 16)
     if _, err := rand.Read(bytes); err != nil {
@@ -249,11 +670,13 @@ type logEntry struct {
     DurationMs     int64  `json:"durationMs,omitempty"`
     BudgetExceeded bool   `json:"budgetExceeded,omitempty"`
     Fallback       bool   `json:"fallback,omitempty"`
+    ClientID       string `json:"clientId,omitempty"`
 }
 
 func logRequest(ctx context.Context, entry logEntry) {
     span := trace.SpanFromContext(ctx)
     entry.TraceID = span.SpanContext().TraceID().String()
+    entry.ClientID = clientID(ctx)
     data, err := json.Marshal(entry)
     if err != nil {
         return
@@ -0,0 +1,80 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/audit"
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/routing"
+)
+
+func TestRouteOutcomesResolveWithinTheCallersTenant(t *testing.T) {
+	server := newTestServer()
+	acme := &Identity{ClientID: "desk", Tenant: "acme", Method: "api-key", Scopes: DefaultScopes}
+	globex := &Identity{ClientID: "desk", Tenant: "globex", Method: "api-key", Scopes: DefaultScopes}
+	route := `{"routeId":"r1","order":{"id":"o1","symbol":"AAPL","quantity":10,"side":"buy"},"targets":[{"id":"%s","latencyMs":5,"availability":1}]}`
+
+	if response := serveAs(server, acme, http.MethodPost, "/api/v1/routes", strings.Replace(route, "%s", "nyse", 1)); response.Code != http.StatusOK {
+		t.Fatalf("acme route: %d %s", response.Code, response.Body)
+	}
+	// A later route of another tenant reusing the ID must not shadow it.
+	if response := serveAs(server, globex, http.MethodPost, "/api/v1/routes", strings.Replace(route, "%s", "lse", 1)); response.Code != http.StatusOK {
+		t.Fatalf("globex route: %d %s", response.Code, response.Body)
+	}
+
+	response := serveAs(server, acme, http.MethodPost, "/api/v1/routes/r1/outcome", `{"status":"fill"}`)
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("acme outcome: %d %s", response.Code, response.Body)
+	}
+	var outcome outcomeResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &outcome); err != nil {
+		t.Fatal(err)
+	}
+	if outcome.TargetID != "nyse" {
+		t.Fatalf("expected acme's own route to be found, got target %q", outcome.TargetID)
+	}
+
+	other := &Identity{ClientID: "desk", Tenant: "initech", Method: "api-key", Scopes: DefaultScopes}
+	if response := serveAs(server, other, http.MethodPost, "/api/v1/routes/r1/outcome", `{"status":"fill"}`); response.Code != http.StatusNotFound {
+		t.Fatalf("expected another tenant's route to be missing, got %d", response.Code)
+	}
+}
+
+func TestRouteOutcomeIsRecordedOnce(t *testing.T) {
+	store := audit.NewChain(audit.NewStore())
+	server := newTestServer(WithAuditStore(store), WithBreakerConfig(routing.BreakerConfig{FailureThreshold: 2, OpenFor: time.Minute}))
+	route := `{"routeId":"r1","order":{"id":"o1","symbol":"AAPL","quantity":10,"side":"buy"},"targets":[{"id":"nyse","latencyMs":5,"availability":1}]}`
+	if response := serveAs(server, nil, http.MethodPost, "/api/v1/routes", route); response.Code != http.StatusOK {
+		t.Fatalf("route: %d %s", response.Code, response.Body)
+	}
+
+	if response := serveAs(server, nil, http.MethodPost, "/api/v1/routes/missing/outcome", `{"status":"fill"}`); response.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown route to be missing, got %d", response.Code)
+	}
+	timeout := `{"status":"timeout","latencyMs":900}`
+	if response := serveAs(server, nil, http.MethodPost, "/api/v1/routes/r1/outcome", timeout); response.Code != http.StatusAccepted {
+		t.Fatalf("outcome: %d %s", response.Code, response.Body)
+	}
+	// A replayed timeout would open the breaker at the threshold of two.
+	if response := serveAs(server, nil, http.MethodPost, "/api/v1/routes/r1/outcome", timeout); response.Code != http.StatusConflict {
+		t.Fatalf("expected a repeated outcome to conflict, got %d %s", response.Code, response.Body)
+	}
+
+	state, _ := server.tenantState("")
+	if got := state.breakers.State("nyse", time.Now()); got != routing.BreakerClosed {
+		t.Fatalf("expected the repeat to leave the breaker alone, got %s", got)
+	}
+	if targets := state.metricCache.Targets(time.Now()); len(targets) != 1 || targets[0].Samples != 2 {
+		t.Fatalf("expected one route and one outcome sample, got %+v", targets)
+	}
+	page, err := store.Query(audit.Query{RouteID: "r1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != 2 || page.Entries[0].Outcome == nil || page.Entries[0].Outcome.Status != "timeout" {
+		t.Fatalf("expected one decision and one outcome, got %+v", page.Entries)
+	}
+}
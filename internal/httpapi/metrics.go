@@ -0,0 +1,122 @@
+package httpapi
+
+import (
+    "errors"
+    "net/http"
+    "time"
+
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/routing"
+)
+
+// maxOverride bounds how long an operator override may last, so a forgotten
+// one cannot outlive the incident it was set for by much.
+const maxOverride = 24 * time.Hour
+
+type metricTargetsResponse struct {
+    Targets []routing.TargetMetrics `json:"targets"`
+}
+
+type overrideRequest struct {
+    LatencyMs       *int64   `json:"latencyMs,omitempty"`
+    Availability    *float64 `json:"availability,omitempty"`
+    DurationSeconds int64    `json:"durationSeconds"`
+}
+
+// Validate checks the request for kind. A pin needs both values; marking a
+// target down takes only a duration.
+func (req overrideRequest) Validate(kind string) error {
+    if req.DurationSeconds <= 0 || time.Duration(req.DurationSeconds)*time.Second > maxOverride {
+        return errors.New("durationSeconds must be between 1 and 86400")
+    }
+    if kind == routing.OverrideDown {
+        if req.LatencyMs != nil || req.Availability != nil {
+            return errors.New("down takes only durationSeconds")
+        }
+        return nil
+    }
+    if req.LatencyMs == nil || *req.LatencyMs < 0 {
+        return errors.New("latencyMs must be >= 0")
+    }
+    if req.Availability == nil || *req.Availability < 0 || *req.Availability > 1 {
+        return errors.New("availability must be between 0 and 1")
+    }
+    return nil
+}
+
+// handleMetricTargets dumps what the tenant's metric cache believes about
+// each target.
+func (s *Server) handleMetricTargets(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+        return
+    }
+    targets := tenantFrom(r.Context()).metricCache.Targets(time.Now().UTC())
+    writeJSON(w, http.StatusOK, metricTargetsResponse{Targets: targets})
+}
+
+// handleMetricOverride returns the handler that pins a target's metrics or
+// marks it down, depending on kind.
+func (s *Server) handleMetricOverride(kind string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+            return
+        }
+        var payload overrideRequest
+        if err := readJSON(r, &payload); err != nil {
+            writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+            return
+        }
+        if err := payload.Validate(kind); err != nil {
+            writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+            return
+        }
+
+        targetID := r.PathValue("targetId")
+        cache := tenantFrom(r.Context()).metricCache
+        until := time.Now().UTC().Add(time.Duration(payload.DurationSeconds) * time.Second)
+        if kind == routing.OverrideDown {
+            cache.MarkDown(targetID, until)
+        } else {
+            cache.Pin(targetID, *payload.LatencyMs, *payload.Availability, until)
+        }
+        logRequest(r.Context(), logEntry{
+            Message:     "metric override: " + kind,
+            Destination: targetID,
+            Status:      http.StatusOK,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        writeJSON(w, http.StatusOK, s.metricTarget(r, targetID))
+    }
+}
+
+// handleMetricReset drops a target's cached metrics and any override.
+func (s *Server) handleMetricReset(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+        return
+    }
+    targetID := r.PathValue("targetId")
+    if !tenantFrom(r.Context()).metricCache.Reset(targetID) {
+        writeJSON(w, http.StatusNotFound, errorResponse{Error: "target not cached"})
+        return
+    }
+    logRequest(r.Context(), logEntry{
+        Message:     "metric override: reset",
+        Destination: targetID,
+        Status:      http.StatusNoContent,
+        Path:        r.URL.Path,
+        Method:      r.Method,
+    })
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) metricTarget(r *http.Request, targetID string) routing.TargetMetrics {
+    for _, target := range tenantFrom(r.Context()).metricCache.Targets(time.Now().UTC()) {
+        if target.TargetID == targetID {
+            return target
+        }
+    }
+    return routing.TargetMetrics{TargetID: targetID}
+}
@@ -1,18 +1,28 @@
 package httpapi
 
 import (
+    "encoding/base64"
     "errors"
+    "net/url"
     "strconv"
     "strings"
+    "time"
 
     "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/audit"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/risk"
     "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/routing"
 )
 
 type routeRequest struct {
-    RouteID string        `json:"routeId"`
-    Order   orderRequest  `json:"order"`
-    Targets []targetInput `json:"targets"`
+    RouteID  string        `json:"routeId"`
+    Strategy string        `json:"strategy"`
+    Weights  *weightsInput `json:"weights,omitempty"`
+    Split    bool          `json:"split"`
+    Order    orderRequest  `json:"order"`
+    Targets  []targetInput `json:"targets"`
+    // Venues narrows routing to these registered venues when Targets is
+    // empty. With neither, every venue registered for the tenant competes.
+    Venues []string `json:"venues,omitempty"`
 }
 
 type orderRequest struct {
@@ -20,35 +30,113 @@ type orderRequest struct {
     Symbol   string `json:"symbol"`
     Quantity int64  `json:"quantity"`
     Side     string `json:"side"`
+    // Price is the limit price; market orders omit it.
+    Price *float64 `json:"price,omitempty"`
 }
 
 type targetInput struct {
-    ID           string  `json:"id"`
-    Name         string  `json:"name"`
-    LatencyMs    int64   `json:"latencyMs"`
+    ID           string   `json:"id"`
+    Name         string   `json:"name"`
+    LatencyMs    int64    `json:"latencyMs"`
+    Availability float64  `json:"availability"`
+    Priority     int      `json:"priority"`
+    FeeBps       float64  `json:"feeBps"`
+    FillRate     *float64 `json:"fillRate,omitempty"`
+    Capacity     int64    `json:"capacity"`
+}
+
+type weightsInput struct {
+    Latency      float64 `json:"latency"`
     Availability float64 `json:"availability"`
-    Priority     int     `json:"priority"`
+    Priority     float64 `json:"priority"`
+    Fee          float64 `json:"fee"`
+    FillRate     float64 `json:"fillRate"`
 }
 
 type routeResponse struct {
-    RouteID string           `json:"routeId"`
-    TraceID string           `json:"traceId"`
-    Decision decisionPayload `json:"decision"`
+    RouteID    string             `json:"routeId"`
+    TraceID    string             `json:"traceId"`
+    Decision   decisionPayload    `json:"decision"`
+    Candidates []candidatePayload `json:"candidates,omitempty"`
 }
 
-type decisionPayload struct {
+type candidatePayload struct {
+    TargetID     string            `json:"targetId"`
+    Rank         int               `json:"rank,omitempty"`
+    Score        *float64          `json:"score,omitempty"`
+    Breakdown    *breakdownPayload `json:"breakdown,omitempty"`
+    DecidedBy    string            `json:"decidedBy,omitempty"`
+    LatencyMs    int64             `json:"latencyMs"`
+    Availability float64           `json:"availability"`
+    Priority     int               `json:"priority"`
+    Filtered     bool              `json:"filtered"`
+    FilteredBy   string            `json:"filteredBy,omitempty"`
+}
+
+type splitRouteResponse struct {
+    RouteID  string         `json:"routeId"`
+    TraceID  string         `json:"traceId"`
+    Strategy string         `json:"strategy"`
+    Fallback bool           `json:"fallback"`
+    Children []childPayload `json:"children"`
+}
+
+type childPayload struct {
+    RouteID  string  `json:"routeId"`
     TargetID string  `json:"targetId"`
+    Quantity int64   `json:"quantity"`
     Reason   string  `json:"reason"`
-    Fallback bool    `json:"fallback"`
     Score    float64 `json:"score"`
 }
 
+type decisionPayload struct {
+    TargetID  string            `json:"targetId"`
+    Reason    string            `json:"reason"`
+    Fallback  bool              `json:"fallback"`
+    Score     float64           `json:"score"`
+    Strategy  string            `json:"strategy"`
+    Breakdown *breakdownPayload `json:"breakdown,omitempty"`
+    DecidedBy string            `json:"decidedBy,omitempty"`
+}
+
+type breakdownPayload struct {
+    Latency      float64 `json:"latency"`
+    Availability float64 `json:"availability"`
+    Priority     float64 `json:"priority"`
+    Fee          float64 `json:"fee"`
+    FillRate     float64 `json:"fillRate"`
+}
+
+type outcomeRequest struct {
+    TargetID  string `json:"targetId"`
+    Status    string `json:"status"`
+    LatencyMs *int64 `json:"latencyMs,omitempty"`
+}
+
+type outcomeResponse struct {
+    RouteID  string `json:"routeId"`
+    OrderID  string `json:"orderId"`
+    TargetID string `json:"targetId"`
+    Status   string `json:"status"`
+    Circuit  string `json:"circuit"`
+}
+
 type errorResponse struct {
     Error string `json:"error"`
 }
 
+// riskErrorResponse explains a pre-trade risk rejection. Code is one of the
+// risk.Code constants.
+type riskErrorResponse struct {
+    Error  string  `json:"error"`
+    Code   string  `json:"code"`
+    Limit  float64 `json:"limit,omitempty"`
+    Actual float64 `json:"actual,omitempty"`
+}
+
 type auditResponse struct {
-    Entries []audit.Entry `json:"entries"`
+    Entries    []audit.Entry `json:"entries"`
+    NextCursor string        `json:"nextCursor,omitempty"`
 }
 
 func (req routeRequest) Validate() error {
@@ -65,8 +153,23 @@ func (req routeRequest) Validate() error {
     if side != "buy" && side != "sell" {
         return errors.New("order.side must be 'buy' or 'sell'")
     }
-    if len(req.Targets) == 0 {
-        return errors.New("targets must include at least one target")
+    if req.Order.Price != nil && *req.Order.Price <= 0 {
+        return errors.New("order.price must be greater than 0")
+    }
+    if len(req.Targets) > 0 && len(req.Venues) > 0 {
+        return errors.New("targets and venues cannot be combined")
+    }
+    seen := make(map[string]bool, len(req.Venues))
+    for idx, id := range req.Venues {
+        if strings.TrimSpace(id) == "" {
+            return errors.New("venues[" + strconv.Itoa(idx) + "] is required")
+        }
+        // A venue listed twice would compete twice, doubling its capacity
+        // in split orders.
+        if seen[id] {
+            return errors.New("venues[" + strconv.Itoa(idx) + "] repeats " + id)
+        }
+        seen[id] = true
     }
     for idx, target := range req.Targets {
         if strings.TrimSpace(target.ID) == "" {
@@ -78,24 +181,181 @@ func (req routeRequest) Validate() error {
         if target.Availability < 0 || target.Availability > 1 {
             return errors.New("targets[" + strconv.Itoa(idx) + "].availability must be between 0 and 1")
         }
+        if target.FillRate != nil && (*target.FillRate < 0 || *target.FillRate > 1) {
+            return errors.New("targets[" + strconv.Itoa(idx) + "].fillRate must be between 0 and 1")
+        }
+        if target.Capacity < 0 {
+            return errors.New("targets[" + strconv.Itoa(idx) + "].capacity must be >= 0")
+        }
+    }
+    if req.Weights != nil {
+        if err := req.Weights.toRouting().Validate(); err != nil {
+            return errors.New("weights must be non-negative with at least one positive factor")
+        }
     }
     return nil
 }
 
+func (req outcomeRequest) Validate() error {
+    if req.LatencyMs != nil && *req.LatencyMs < 0 {
+        return errors.New("latencyMs must be >= 0")
+    }
+    switch req.Status {
+    case "fill", "reject", "timeout":
+        return nil
+    }
+    return errors.New("status must be 'fill', 'reject' or 'timeout'")
+}
+
+// Succeeded reports whether the outcome counts as a success for the target's
+// circuit breaker. Rejects and timeouts both count as failures.
+func (req outcomeRequest) Succeeded() bool {
+    return req.Status == "fill"
+}
+
+// Reachable reports whether the target answered at all. Only timeouts count
+// against the target's cached availability.
+func (req outcomeRequest) Reachable() bool {
+    return req.Status != "timeout"
+}
+
+// ObservedLatency returns the reported latency, or -1 when none was sent.
+func (req outcomeRequest) ObservedLatency() int64 {
+    if req.LatencyMs == nil {
+        return -1
+    }
+    return *req.LatencyMs
+}
+
+func (w weightsInput) toRouting() routing.Weights {
+    return routing.Weights{
+        Latency:      w.Latency,
+        Availability: w.Availability,
+        Priority:     w.Priority,
+        Fee:          w.Fee,
+        FillRate:     w.FillRate,
+    }
+}
+
+func newBreakdownPayload(breakdown *routing.Breakdown) *breakdownPayload {
+    if breakdown == nil {
+        return nil
+    }
+    return &breakdownPayload{
+        Latency:      breakdown.Latency,
+        Availability: breakdown.Availability,
+        Priority:     breakdown.Priority,
+        Fee:          breakdown.Fee,
+        FillRate:     breakdown.FillRate,
+    }
+}
+
+// RiskOrder returns what the pre-trade risk checks need to know about the
+// order.
+func (req routeRequest) RiskOrder() risk.Order {
+    order := risk.Order{Symbol: req.Order.Symbol, Quantity: req.Order.Quantity}
+    if req.Order.Price != nil {
+        order.Price = *req.Order.Price
+    }
+    return order
+}
+
 func (req routeRequest) TargetsToRouting() []routing.Target {
     targets := make([]routing.Target, 0, len(req.Targets))
     for _, target := range req.Targets {
+        // Targets that do not report a fill rate are assumed to fill fully.
+        fillRate := 1.0
+        if target.FillRate != nil {
+            fillRate = *target.FillRate
+        }
         targets = append(targets, routing.Target{
             ID:           target.ID,
             Name:         target.Name,
             LatencyMs:    target.LatencyMs,
             Availability: target.Availability,
             Priority:     target.Priority,
+            FeeBps:       target.FeeBps,
+            FillRate:     fillRate,
+            Capacity:     target.Capacity,
         })
     }
     return targets
 }
 
+func newCandidatePayloads(candidates []routing.Candidate) []candidatePayload {
+    result := make([]candidatePayload, 0, len(candidates))
+    for _, candidate := range candidates {
+        entry := candidatePayload{
+            TargetID:     candidate.Target.ID,
+            Rank:         candidate.Rank,
+            Breakdown:    newBreakdownPayload(candidate.Breakdown),
+            DecidedBy:    candidate.DecidedBy,
+            LatencyMs:    candidate.Target.LatencyMs,
+            Availability: candidate.Target.Availability,
+            Priority:     candidate.Target.Priority,
+            Filtered:     candidate.FilteredBy != "",
+            FilteredBy:   candidate.FilteredBy,
+        }
+        if !entry.Filtered {
+            score := candidate.Score
+            entry.Score = &score
+        }
+        result = append(result, entry)
+    }
+    return result
+}
+
+// parseAuditQuery reads the audit filters from the query string. Times are
+// RFC 3339; cursor is the opaque nextCursor of a previous page.
+func parseAuditQuery(values url.Values) (audit.Query, error) {
+    query := audit.Query{
+        OrderID:  strings.TrimSpace(values.Get("orderId")),
+        RouteID:  strings.TrimSpace(values.Get("routeId")),
+        TargetID: strings.TrimSpace(values.Get("targetId")),
+        Limit:    parseLimit(values.Get("limit"), audit.DefaultQueryLimit),
+    }
+    if raw := values.Get("fallback"); raw != "" {
+        fallback, err := strconv.ParseBool(raw)
+        if err != nil {
+            return audit.Query{}, errors.New("fallback must be true or false")
+        }
+        query.Fallback = &fallback
+    }
+    if raw := values.Get("from"); raw != "" {
+        from, err := time.Parse(time.RFC3339Nano, raw)
+        if err != nil {
+            return audit.Query{}, errors.New("from must be an RFC 3339 timestamp")
+        }
+        query.From = from
+    }
+    if raw := values.Get("to"); raw != "" {
+        to, err := time.Parse(time.RFC3339Nano, raw)
+        if err != nil {
+            return audit.Query{}, errors.New("to must be an RFC 3339 timestamp")
+        }
+        query.To = to
+    }
+    if raw := values.Get("cursor"); raw != "" {
+        cursor, err := base64.RawURLEncoding.DecodeString(raw)
+        if err != nil || len(cursor) == 0 {
+            return audit.Query{}, errors.New("cursor is invalid")
+        }
+        query.Cursor = string(cursor)
+    }
+    return query, nil
+}
+
+// encodeCursor wraps the audit backend's cursor, which the backend validates
+// when it is presented again.
+func encodeCursor(cursor string) string {
+    return base64.RawURLEncoding.EncodeToString([]byte(cursor))
+}
+
+func parseBool(raw string) bool {
+    value, err := strconv.ParseBool(raw)
+    return err == nil && value
+}
+
 func parseLimit(raw string, fallback int) int {
     if raw == "" {
         return fallback
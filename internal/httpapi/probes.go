@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+    "context"
+    "net/http"
+
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/prober"
+)
+
+// WithProber actively probes registered venues that configure a probe and
+// feeds the results into each tenant's metric cache. Probing starts with
+// RunProbes.
+func WithProber(config prober.Config) Option {
+    return func(s *Server) {
+        s.probeConfig = &config
+    }
+}
+
+// RunProbes probes venues until ctx is done. It returns at once when probing
+// is not configured.
+func (s *Server) RunProbes(ctx context.Context) {
+    if s.prober != nil {
+        s.prober.Run(ctx)
+    }
+}
+
+// observeProbe folds what the prober measured into the venue's cached
+// metrics as one more sample. A venue that never answered in the probe window
+// has no measured latency, so only its availability is recorded.
+func (s *Server) observeProbe(result prober.Result) {
+    state, err := s.tenantState(result.Tenant)
+    if err != nil {
+        return
+    }
+    latencyMs := result.LatencyP50Ms
+    if result.Availability == 0 {
+        latencyMs = -1
+    }
+    state.metricCache.Record(result.VenueID, latencyMs, result.Availability, result.LastProbeAt)
+}
+
+type probesResponse struct {
+    Enabled bool            `json:"enabled"`
+    Probes  []prober.Result `json:"probes"`
+}
+
+// handleProbes reports the latest probe results for the tenant's venues.
+func (s *Server) handleProbes(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+        return
+    }
+    if s.prober == nil {
+        writeJSON(w, http.StatusOK, probesResponse{Probes: []prober.Result{}})
+        return
+    }
+    writeJSON(w, http.StatusOK, probesResponse{Enabled: true, Probes: s.prober.Results(tenantFrom(r.Context()).tenant.ID)})
+}
@@ -0,0 +1,65 @@
+package httpapi
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/audit"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/risk"
+)
+
+// WithRiskLimits runs the pre-trade risk checks in limits on every order
+// before it is routed.
+func WithRiskLimits(limits *risk.Limits) Option {
+    return func(s *Server) {
+        s.riskLimits = limits
+    }
+}
+
+// checkRisk applies the pre-trade risk checks to the order. A rejected order
+// is answered with 422 and the violated check, and recorded in the audit log;
+// checkRisk then returns false.
+func (s *Server) checkRisk(ctx context.Context, w http.ResponseWriter, r *http.Request, routeID string, payload routeRequest) bool {
+    err := s.riskLimits.Check(payload.RiskOrder())
+    if err == nil {
+        return true
+    }
+    var violation *risk.Violation
+    if !errors.As(err, &violation) {
+        violation = &risk.Violation{Message: err.Error()}
+    }
+
+    writeJSON(w, http.StatusUnprocessableEntity, riskErrorResponse{
+        Error:  violation.Message,
+        Code:   violation.Code,
+        Limit:  violation.Limit,
+        Actual: violation.Actual,
+    })
+    logRequest(ctx, logEntry{
+        Message:     "risk check failed: " + violation.Code,
+        RouteID:     routeID,
+        Destination: "",
+        Status:      http.StatusUnprocessableEntity,
+        Path:        r.URL.Path,
+        Method:      r.Method,
+    })
+    s.recordAudit(ctx, r, audit.Entry{
+        Timestamp: time.Now().UTC(),
+        RouteID:   routeID,
+        OrderID:   payload.Order.ID,
+        Quantity:  payload.Order.Quantity,
+        Reason:    "risk-rejected",
+        Strategy:  payload.Strategy,
+        Rejection: &audit.Rejection{
+            Code:    violation.Code,
+            Message: violation.Message,
+            Symbol:  payload.Order.Symbol,
+            Side:    strings.ToLower(strings.TrimSpace(payload.Order.Side)),
+            Price:   payload.Order.Price,
+        },
+    })
+    return false
+}
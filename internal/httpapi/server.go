@@ -2,26 +2,150 @@ package httpapi
 
 import (
     "net/http"
+    "net/netip"
+    "strconv"
+    "sync"
     "time"
 
     "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/audit"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/jwtauth"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/prober"
     "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/ratelimit"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/risk"
     "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/routing"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/tenant"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/venue"
 )
 
 type Server struct {
-    limiter     *ratelimit.Limiter
-    auditStore  *audit.Store
-    metricCache *routing.MetricCache
-    mux         *http.ServeMux
+    limiter        ratelimit.Limiter
+    addressLimiter ratelimit.Limiter
+    auditStore     audit.Backend
+    strategies     *routing.Registry
+    breakerConfig  routing.BreakerConfig
+    metricConfig   routing.MetricConfig
+    venues         venue.Store
+    mux            *http.ServeMux
+
+    newMetricCache func(tenant string, config routing.MetricConfig) routing.MetricCache
+
+    probeConfig *prober.Config
+    prober      *prober.Prober
+
+    riskLimits *risk.Limits
+
+    // outcomeMu serializes outcome reports, so a route's outcome is checked
+    // for and recorded without another report slipping in between.
+    outcomeMu sync.Mutex
+
+    trustedProxies     []netip.Prefix
+    trustedProxyHeader string
+    apiKeys            []APIKey
+    jwtVerifier        *jwtauth.Verifier
+    clientCertAuth     bool
+    clientCertScopes   map[string][]string
+
+    tenants       *tenant.Registry
+    tenantLimiter func(requestsPerMin int) ratelimit.Limiter
+    tenantMu      sync.Mutex
+    tenantStates  map[string]*tenantState
+}
+
+// Option customizes a Server built by NewServer.
+type Option func(*Server)
+
+// WithScoringWeights replaces the default weights used by the weighted-score
+// strategy when a request does not supply its own.
+func WithScoringWeights(weights routing.Weights) Option {
+    return func(s *Server) {
+        s.strategies.Register(routing.NewWeightedScore(weights))
+    }
+}
+
+// WithAuditStore replaces the default in-memory audit store. Wrap the store in
+// an audit.Chain for the verify endpoint to be meaningful.
+func WithAuditStore(store audit.Backend) Option {
+    return func(s *Server) {
+        s.auditStore = store
+    }
+}
+
+// DefaultAddressRequestsPerMin is the per-IP quota applied before
+// authentication unless WithAddressRateLimit replaces it. It is well above a
+// single client's quota, as many authenticated clients may share one NAT or
+// proxy address.
+const DefaultAddressRequestsPerMin = 1200
+
+// WithAddressRateLimit replaces the limiter applied per client IP before
+// authentication, which defaults to a local fixed window of
+// DefaultAddressRequestsPerMin.
+func WithAddressRateLimit(limiter ratelimit.Limiter) Option {
+    return func(s *Server) {
+        s.addressLimiter = limiter
+    }
+}
+
+// WithBreakerConfig replaces the default per-target circuit breaker settings.
+func WithBreakerConfig(config routing.BreakerConfig) Option {
+    return func(s *Server) {
+        s.breakerConfig = config
+    }
+}
+
+// WithMetricConfig replaces the default smoothing of each tenant's metric
+// cache.
+func WithMetricConfig(config routing.MetricConfig) Option {
+    return func(s *Server) {
+        s.metricConfig = config
+    }
+}
+
+// WithMetricCache replaces the in-memory metric cache each tenant gets, for
+// example with one shared between replicas. newCache is called once per
+// tenant with the configured smoothing.
+func WithMetricCache(newCache func(tenant string, config routing.MetricConfig) routing.MetricCache) Option {
+    return func(s *Server) {
+        s.newMetricCache = newCache
+    }
 }
 
-func NewServer(limiter *ratelimit.Limiter) *Server {
+// WithTenants restricts callers to the tenants in registry and applies each
+// tenant's venue set and request quota. newLimiter builds the limiter that
+// enforces a tenant's combined quota; nil uses a local fixed window.
+func WithTenants(registry *tenant.Registry, newLimiter func(requestsPerMin int) ratelimit.Limiter) Option {
+    return func(s *Server) {
+        s.tenants = registry
+        if newLimiter != nil {
+            s.tenantLimiter = newLimiter
+        }
+    }
+}
+
+func NewServer(limiter ratelimit.Limiter, opts ...Option) *Server {
     server := &Server{
-        limiter:     limiter,
-        auditStore:  audit.NewStore(),
-        metricCache: routing.NewMetricCache(30 * time.Second),
-        mux:         http.NewServeMux(),
+        limiter:       limiter,
+        auditStore:    audit.NewChain(audit.NewStore()),
+        strategies:    routing.DefaultRegistry(),
+        breakerConfig: routing.DefaultBreakerConfig(),
+        metricConfig:  routing.DefaultMetricConfig(),
+        venues:        venue.NewMemoryStore(),
+        mux:           http.NewServeMux(),
+        newMetricCache: func(_ string, config routing.MetricConfig) routing.MetricCache {
+            return routing.NewMemoryMetricCache(config)
+        },
+        tenantLimiter: func(requestsPerMin int) ratelimit.Limiter {
+            return ratelimit.NewFixedWindow(ratelimit.Config{MaxRequests: requestsPerMin, Window: time.Minute})
+        },
+        tenantStates: make(map[string]*tenantState),
+    }
+    for _, opt := range opts {
+        opt(server)
+    }
+    if server.addressLimiter == nil {
+        server.addressLimiter = ratelimit.NewFixedWindow(ratelimit.Config{MaxRequests: DefaultAddressRequestsPerMin, Window: time.Minute})
+    }
+    if server.probeConfig != nil {
+        server.prober = prober.New(*server.probeConfig, server.venues, server.observeProbe)
     }
     server.routes()
     return server
@@ -29,20 +153,87 @@ func NewServer(limiter *ratelimit.Limiter) *Server {
 
 func (s *Server) routes() {
     s.mux.HandleFunc("/api/v1/health", s.handleHealth)
-    s.mux.HandleFunc("/api/v1/routes", s.handleRoutes)
-    s.mux.HandleFunc("/api/v1/audit/routes", s.handleAudit)
+    s.mux.HandleFunc("/api/v1/routes", s.requireScope(ScopeRoutesWrite, s.handleRoutes))
+    s.mux.HandleFunc("/api/v1/routes/{routeId}/outcome", s.requireScope(ScopeRoutesWrite, s.handleRouteOutcome))
+    s.mux.HandleFunc("/api/v1/audit/routes", s.requireScope(ScopeAuditRead, s.handleAudit))
+    s.mux.HandleFunc("/api/v1/audit/verify", s.requireScope(ScopeAuditRead, s.handleAuditVerify))
+    s.mux.HandleFunc("/api/v1/venues", s.requireReadWriteScope(ScopeVenuesRead, ScopeVenuesWrite, s.handleVenues))
+    s.mux.HandleFunc("/api/v1/venues/{venueId}", s.requireReadWriteScope(ScopeVenuesRead, ScopeVenuesWrite, s.handleVenue))
+    s.mux.HandleFunc("/api/v1/admin/probes", s.requireScope(ScopeAdmin, s.handleProbes))
+    s.mux.HandleFunc("/api/v1/admin/metrics/targets", s.requireScope(ScopeAdmin, s.handleMetricTargets))
+    s.mux.HandleFunc("/api/v1/admin/metrics/targets/{targetId}/pin", s.requireScope(ScopeAdmin, s.handleMetricOverride(routing.OverridePin)))
+    s.mux.HandleFunc("/api/v1/admin/metrics/targets/{targetId}/down", s.requireScope(ScopeAdmin, s.handleMetricOverride(routing.OverrideDown)))
+    s.mux.HandleFunc("/api/v1/admin/metrics/targets/{targetId}/reset", s.requireScope(ScopeAdmin, s.handleMetricReset))
 }
 
+// Handler limits requests by client IP before authenticating them, so
+// credentials cannot be guessed at an unlimited rate, and by client and
+// tenant once they are authenticated.
 func (s *Server) Handler() http.Handler {
-    return s.withRateLimit(s.mux)
+    return s.withAddressRateLimit(s.withAuth(s.withTenant(s.withRateLimit(s.mux))))
+}
+
+// withAddressRateLimit applies the limiter per client IP ahead of
+// authentication. Without authentication withRateLimit already keys every
+// request by IP, so this is skipped.
+func (s *Server) withAddressRateLimit(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if !s.authRequired() {
+            next.ServeHTTP(w, r)
+            return
+        }
+        if s.rejectRateLimited(w, s.addressLimiter.Allow(s.clientIP(r), time.Now())) {
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
 }
 
+// withRateLimit applies the limiter per authenticated client, then the
+// tenant's quota. Requests that needed no credentials were already limited
+// by IP.
 func (s *Server) withRateLimit(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        if !s.limiter.Allow(clientIP(r), time.Now()) {
-            writeJSON(w, http.StatusTooManyRequests, errorResponse{Error: "rate limit exceeded"})
+        identity, authenticated := IdentityFrom(r.Context())
+        if !authenticated && s.authRequired() {
+            next.ServeHTTP(w, r)
+            return
+        }
+        now := time.Now()
+        key := s.clientIP(r)
+        if authenticated {
+            key = "client:" + identity.Tenant + "/" + identity.ClientID
+        }
+        result := s.limiter.Allow(key, now)
+        if state := tenantFrom(r.Context()); result.Allowed && state != nil && state.limiter != nil {
+            result = state.limiter.Allow("tenant:"+state.tenant.ID, now)
+        }
+        if s.rejectRateLimited(w, result) {
             return
         }
         next.ServeHTTP(w, r)
     })
 }
+
+// rejectRateLimited sets the rate limit headers of result and answers 429
+// when it was not allowed.
+func (s *Server) rejectRateLimited(w http.ResponseWriter, result ratelimit.Result) bool {
+    header := w.Header()
+    header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+    header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+    header.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(result.ResetAfter), 10))
+    if result.Allowed {
+        return false
+    }
+    header.Set("Retry-After", strconv.FormatInt(max(1, ceilSeconds(result.RetryAfter)), 10))
+    writeJSON(w, http.StatusTooManyRequests, errorResponse{Error: "rate limit exceeded"})
+    return true
+}
+
+// ceilSeconds rounds d up to whole seconds, the unit the rate limit headers use.
+func ceilSeconds(d time.Duration) int64 {
+    if d <= 0 {
+        return 0
+    }
+    return int64((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,66 @@
+package httpapi
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/routing"
+)
+
+// RestoreMetrics warms each tenant's metric cache from the snapshot at path,
+// skipping tenants that are no longer configured and metrics older than the
+// cache TTL. It returns how many targets were restored.
+func (s *Server) RestoreMetrics(path string) (int, error) {
+    snapshot, err := routing.ReadSnapshot(path)
+    if err != nil {
+        return 0, err
+    }
+    now := time.Now().UTC()
+    restored := 0
+    for id, targets := range snapshot.Tenants {
+        state, err := s.tenantState(id)
+        if err != nil {
+            continue
+        }
+        if cache, ok := state.metricCache.(routing.Snapshotter); ok {
+            restored += cache.Restore(targets, now)
+        }
+    }
+    return restored, nil
+}
+
+// SaveMetrics writes a snapshot of every tenant's metric cache to path.
+// Caches that live outside the process are left out.
+func (s *Server) SaveMetrics(path string) error {
+    s.tenantMu.Lock()
+    snapshot := routing.Snapshot{
+        SavedAt: time.Now().UTC(),
+        Tenants: make(map[string][]routing.TargetSnapshot, len(s.tenantStates)),
+    }
+    for id, state := range s.tenantStates {
+        if cache, ok := state.metricCache.(routing.Snapshotter); ok {
+            snapshot.Tenants[id] = cache.Snapshot()
+        }
+    }
+    s.tenantMu.Unlock()
+
+    return routing.WriteSnapshot(path, snapshot)
+}
+
+// RunMetricSnapshots saves a snapshot to path every interval until ctx is
+// done. Failures are logged and retried on the next tick.
+func (s *Server) RunMetricSnapshots(ctx context.Context, path string, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if err := s.SaveMetrics(path); err != nil {
+                log.Printf("failed to save metric snapshot: %v", err)
+            }
+        }
+    }
+}
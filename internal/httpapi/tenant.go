@@ -0,0 +1,74 @@
+package httpapi
+
+import (
+    "context"
+    "net/http"
+
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/ratelimit"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/routing"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/tenant"
+)
+
+// tenantState is everything one tenant must not share with another: the
+// metric cache and circuit breakers fed by its own outcome reports, and the
+// limiter enforcing its combined quota.
+type tenantState struct {
+    tenant      tenant.Tenant
+    metricCache routing.MetricCache
+    breakers    *routing.Breakers
+    limiter     ratelimit.Limiter
+}
+
+type tenantKey struct{}
+
+// tenantFrom returns the tenant withTenant resolved. It is set for every
+// request except health checks.
+func tenantFrom(ctx context.Context) *tenantState {
+    state, _ := ctx.Value(tenantKey{}).(*tenantState)
+    return state
+}
+
+// withTenant resolves the caller's tenant and rejects tenants that are not
+// configured.
+func (s *Server) withTenant(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/api/v1/health" {
+            next.ServeHTTP(w, r)
+            return
+        }
+        id := tenantID(r.Context())
+        if id == tenant.DefaultID {
+            id = ""
+        }
+        state, err := s.tenantState(id)
+        if err != nil {
+            writeJSON(w, http.StatusForbidden, errorResponse{Error: err.Error()})
+            return
+        }
+        next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, state)))
+    })
+}
+
+// tenantState returns the state of tenant id, creating it on first use.
+func (s *Server) tenantState(id string) (*tenantState, error) {
+    config, err := s.tenants.Lookup(id)
+    if err != nil {
+        return nil, err
+    }
+
+    s.tenantMu.Lock()
+    defer s.tenantMu.Unlock()
+    if state, ok := s.tenantStates[id]; ok {
+        return state, nil
+    }
+    state := &tenantState{
+        tenant:      config,
+        metricCache: s.newMetricCache(id, s.metricConfig),
+        breakers:    routing.NewBreakers(s.breakerConfig),
+    }
+    if config.RequestsPerMin > 0 {
+        state.limiter = s.tenantLimiter(config.RequestsPerMin)
+    }
+    s.tenantStates[id] = state
+    return state, nil
+}
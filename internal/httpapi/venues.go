@@ -0,0 +1,218 @@
+package httpapi
+
+import (
+    "errors"
+    "net/http"
+    "time"
+
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/prober"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/routing"
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/venue"
+)
+
+// WithVenueStore replaces the default in-memory venue registry.
+func WithVenueStore(store venue.Store) Option {
+    return func(s *Server) {
+        s.venues = store
+    }
+}
+
+type venuesResponse struct {
+    Venues []venue.Venue `json:"venues"`
+}
+
+// handleVenues lists the tenant's venues and registers new ones.
+func (s *Server) handleVenues(w http.ResponseWriter, r *http.Request) {
+    tenant := tenantFrom(r.Context())
+    switch r.Method {
+    case http.MethodGet:
+        venues, err := s.venues.List(tenant.tenant.ID)
+        if err != nil {
+            writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to read venues"})
+            return
+        }
+        writeJSON(w, http.StatusOK, venuesResponse{Venues: venues})
+    case http.MethodPost:
+        payload, ok := s.readVenue(w, r, "")
+        if !ok {
+            return
+        }
+        err := s.venues.Create(tenant.tenant.ID, payload)
+        if errors.Is(err, venue.ErrExists) {
+            writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+            return
+        }
+        if err != nil {
+            writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to save venue"})
+            return
+        }
+        logRequest(r.Context(), logEntry{
+            Message:     "venue created",
+            Destination: payload.ID,
+            Status:      http.StatusCreated,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        w.Header().Set("Location", "/api/v1/venues/"+payload.ID)
+        writeJSON(w, http.StatusCreated, payload)
+    default:
+        writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+    }
+}
+
+// handleVenue reads, replaces or removes one venue.
+func (s *Server) handleVenue(w http.ResponseWriter, r *http.Request) {
+    tenant := tenantFrom(r.Context())
+    id := r.PathValue("venueId")
+    switch r.Method {
+    case http.MethodGet:
+        found, ok, err := s.venues.Get(tenant.tenant.ID, id)
+        if err != nil {
+            writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to read venue"})
+            return
+        }
+        if !ok {
+            writeJSON(w, http.StatusNotFound, errorResponse{Error: "venue not found"})
+            return
+        }
+        writeJSON(w, http.StatusOK, found)
+    case http.MethodPut:
+        payload, ok := s.readVenue(w, r, id)
+        if !ok {
+            return
+        }
+        if err := s.venues.Put(tenant.tenant.ID, payload); err != nil {
+            writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to save venue"})
+            return
+        }
+        logRequest(r.Context(), logEntry{
+            Message:     "venue replaced",
+            Destination: payload.ID,
+            Status:      http.StatusOK,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        writeJSON(w, http.StatusOK, payload)
+    case http.MethodDelete:
+        deleted, err := s.venues.Delete(tenant.tenant.ID, id)
+        if err != nil {
+            writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to delete venue"})
+            return
+        }
+        if !deleted {
+            writeJSON(w, http.StatusNotFound, errorResponse{Error: "venue not found"})
+            return
+        }
+        logRequest(r.Context(), logEntry{
+            Message:     "venue deleted",
+            Destination: id,
+            Status:      http.StatusNoContent,
+            Path:        r.URL.Path,
+            Method:      r.Method,
+        })
+        w.WriteHeader(http.StatusNoContent)
+    default:
+        writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+    }
+}
+
+// readVenue decodes and validates a venue body. pathID, when set, is the ID
+// from the URL, which the body may omit but must not contradict.
+func (s *Server) readVenue(w http.ResponseWriter, r *http.Request, pathID string) (venue.Venue, bool) {
+    var payload venue.Venue
+    if err := readJSON(r, &payload); err != nil {
+        writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+        return venue.Venue{}, false
+    }
+    if pathID != "" {
+        if payload.ID != "" && payload.ID != pathID {
+            writeJSON(w, http.StatusBadRequest, errorResponse{Error: "id does not match the venue path"})
+            return venue.Venue{}, false
+        }
+        payload.ID = pathID
+    }
+    if err := payload.Validate(); err != nil {
+        writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+        return venue.Venue{}, false
+    }
+    tenant := tenantFrom(r.Context())
+    if !tenant.tenant.AllowsVenue(payload.ID) {
+        writeJSON(w, http.StatusForbidden, errorResponse{Error: "venue " + payload.ID + " is not permitted for this tenant"})
+        return venue.Venue{}, false
+    }
+    if s.denyProbeChange(w, r, tenant.tenant.ID, payload) {
+        return venue.Venue{}, false
+    }
+    payload.UpdatedAt = time.Now().UTC()
+    return payload, true
+}
+
+// denyProbeChange keeps probes, which make the engine connect wherever they
+// point, to admins, and refuses addresses probes may not reach. Keeping a
+// venue's probe as it is, or removing it, needs no more than venues:write.
+func (s *Server) denyProbeChange(w http.ResponseWriter, r *http.Request, tenant string, payload venue.Venue) bool {
+    if payload.Probe == nil {
+        return false
+    }
+    existing, ok, err := s.venues.Get(tenant, payload.ID)
+    if err != nil {
+        writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to read venue"})
+        return true
+    }
+    if ok && existing.Probe != nil && *existing.Probe == *payload.Probe {
+        return false
+    }
+    if s.denyScope(w, r, ScopeAdmin) {
+        return true
+    }
+    var config prober.Config
+    if s.probeConfig != nil {
+        config = *s.probeConfig
+    }
+    if err := config.CheckTarget(*payload.Probe); err != nil {
+        writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid venue: " + err.Error()})
+        return true
+    }
+    return false
+}
+
+// registryTargets draws routing candidates from the tenant's registered
+// venues, narrowed to ids when any are given. Explicitly requested venues
+// must exist; without a list, venues outside the tenant's venue set are
+// skipped.
+func (s *Server) registryTargets(tenant *tenantState, ids []string) ([]routing.Target, error) {
+    if len(ids) > 0 {
+        targets := make([]routing.Target, 0, len(ids))
+        for _, id := range ids {
+            found, ok, err := s.venues.Get(tenant.tenant.ID, id)
+            if err != nil {
+                return nil, err
+            }
+            if !ok {
+                return nil, errUnknownVenue{id: id}
+            }
+            targets = append(targets, found.Target())
+        }
+        return targets, nil
+    }
+
+    venues, err := s.venues.List(tenant.tenant.ID)
+    if err != nil {
+        return nil, err
+    }
+    targets := make([]routing.Target, 0, len(venues))
+    for _, registered := range venues {
+        if tenant.tenant.AllowsVenue(registered.ID) {
+            targets = append(targets, registered.Target())
+        }
+    }
+    return targets, nil
+}
+
+type errUnknownVenue struct {
+    id string
+}
+
+func (e errUnknownVenue) Error() string {
+    return "unknown venue " + e.id
+}
@@ -0,0 +1,159 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/prober"
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/ratelimit"
+)
+
+// serveAs sends a request past authentication as identity, or as an
+// unauthenticated caller when identity is nil.
+func serveAs(s *Server, identity *Identity, method, path, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	if identity != nil {
+		request = request.WithContext(withIdentity(request.Context(), *identity))
+	}
+	recorder := httptest.NewRecorder()
+	s.withTenant(s.mux).ServeHTTP(recorder, request)
+	return recorder
+}
+
+// captureLogs returns what fn's request logs wrote to stdout.
+func captureLogs(t *testing.T, fn func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+	fn()
+	writer.Close()
+	logs, _ := io.ReadAll(reader)
+	return string(logs)
+}
+
+func newTestServer(opts ...Option) *Server {
+	return NewServer(ratelimit.NewFixedWindow(ratelimit.Config{MaxRequests: 1000, Window: time.Minute}), opts...)
+}
+
+func TestVenueProbesRequireAdminAndPublicAddresses(t *testing.T) {
+	server := newTestServer()
+	writer := &Identity{ClientID: "ops", Method: "jwt", Scopes: []string{ScopeVenuesWrite}}
+	admin := &Identity{ClientID: "root", Method: "jwt", Scopes: []string{ScopeVenuesWrite, ScopeAdmin}}
+	probed := `{"id":"nyse","latencyMs":5,"availability":1,"probe":{"type":"http","address":"https://nyse.example.com/health"}}`
+
+	if response := serveAs(server, writer, http.MethodPost, "/api/v1/venues", probed); response.Code != http.StatusForbidden {
+		t.Fatalf("expected venues:write alone to be refused a probe, got %d %s", response.Code, response.Body)
+	}
+	if response := serveAs(server, admin, http.MethodPost, "/api/v1/venues", probed); response.Code != http.StatusCreated {
+		t.Fatalf("expected admin to configure a probe, got %d %s", response.Code, response.Body)
+	}
+	// Editing other fields keeps the probe and needs no admin.
+	edited := strings.Replace(probed, `"latencyMs":5`, `"latencyMs":7`, 1)
+	if response := serveAs(server, writer, http.MethodPut, "/api/v1/venues/nyse", edited); response.Code != http.StatusOK {
+		t.Fatalf("expected an unchanged probe to be accepted, got %d %s", response.Code, response.Body)
+	}
+
+	for _, address := range []string{"http://169.254.169.254/latest/meta-data/", "http://127.0.0.1:6379/", "http://10.0.0.5:5432/"} {
+		body := strings.Replace(probed, "https://nyse.example.com/health", address, 1)
+		if response := serveAs(server, admin, http.MethodPut, "/api/v1/venues/nyse", body); response.Code != http.StatusBadRequest {
+			t.Fatalf("expected probe of %s to be refused, got %d %s", address, response.Code, response.Body)
+		}
+	}
+}
+
+func TestVenueLifecycleIsLogged(t *testing.T) {
+	server := newTestServer()
+	body := `{"id":"nyse","latencyMs":5,"availability":1,"capacity":100}`
+
+	var created, duplicate, mismatched, replaced, deleted, missing *httptest.ResponseRecorder
+	logs := captureLogs(t, func() {
+		created = serveAs(server, nil, http.MethodPost, "/api/v1/venues", body)
+		duplicate = serveAs(server, nil, http.MethodPost, "/api/v1/venues", body)
+		mismatched = serveAs(server, nil, http.MethodPut, "/api/v1/venues/lse", body)
+		replaced = serveAs(server, nil, http.MethodPut, "/api/v1/venues/nyse", `{"latencyMs":9,"availability":1}`)
+		deleted = serveAs(server, nil, http.MethodDelete, "/api/v1/venues/nyse", "")
+		missing = serveAs(server, nil, http.MethodDelete, "/api/v1/venues/nyse", "")
+	})
+
+	if created.Code != http.StatusCreated || created.Header().Get("Location") != "/api/v1/venues/nyse" {
+		t.Fatalf("create: %d %q", created.Code, created.Header().Get("Location"))
+	}
+	if duplicate.Code != http.StatusConflict {
+		t.Fatalf("expected a duplicate venue to conflict, got %d", duplicate.Code)
+	}
+	if mismatched.Code != http.StatusBadRequest {
+		t.Fatalf("expected a body contradicting the path to be refused, got %d", mismatched.Code)
+	}
+	if replaced.Code != http.StatusOK || !strings.Contains(replaced.Body.String(), `"latencyMs":9`) {
+		t.Fatalf("replace: %d %s", replaced.Code, replaced.Body)
+	}
+	if deleted.Code != http.StatusNoContent || missing.Code != http.StatusNotFound {
+		t.Fatalf("delete: %d, then %d", deleted.Code, missing.Code)
+	}
+	for _, message := range []string{"venue created", "venue replaced", "venue deleted"} {
+		if strings.Count(logs, `"message":"`+message+`"`) != 1 {
+			t.Fatalf("expected one %q log line, got:\n%s", message, logs)
+		}
+	}
+}
+
+func TestRoutesDrawOnRegisteredVenues(t *testing.T) {
+	server := newTestServer()
+	for _, body := range []string{
+		`{"id":"nyse","latencyMs":5,"availability":1,"capacity":100}`,
+		`{"id":"lse","latencyMs":50,"availability":1,"capacity":100}`,
+	} {
+		if response := serveAs(server, nil, http.MethodPost, "/api/v1/venues", body); response.Code != http.StatusCreated {
+			t.Fatalf("create: %d %s", response.Code, response.Body)
+		}
+	}
+	order := `"order":{"id":"o1","symbol":"AAPL","quantity":10,"side":"buy"}`
+
+	response := serveAs(server, nil, http.MethodPost, "/api/v1/routes", `{`+order+`,"venues":["lse"]}`)
+	if response.Code != http.StatusOK {
+		t.Fatalf("route: %d %s", response.Code, response.Body)
+	}
+	var routed routeResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &routed); err != nil {
+		t.Fatal(err)
+	}
+	if routed.Decision.TargetID != "lse" {
+		t.Fatalf("expected routing to be narrowed to lse, got %q", routed.Decision.TargetID)
+	}
+
+	// Measured metrics win over the registered baseline, which routing does
+	// not fold into the cache.
+	server.observeProbe(prober.Result{VenueID: "lse", Availability: 1, LatencyP50Ms: 2, LastProbeAt: time.Now().UTC()})
+	for i := 0; i < 3; i++ {
+		serveAs(server, nil, http.MethodPost, "/api/v1/routes", `{`+order+`}`)
+	}
+	state, _ := server.tenantState("")
+	if targets := state.metricCache.Targets(time.Now()); len(targets) != 1 || targets[0].TargetID != "lse" || targets[0].Samples != 1 {
+		t.Fatalf("expected only the probe to be cached, got %+v", targets)
+	}
+	response = serveAs(server, nil, http.MethodPost, "/api/v1/routes", `{`+order+`}`)
+	if err := json.Unmarshal(response.Body.Bytes(), &routed); err != nil {
+		t.Fatal(err)
+	}
+	if routed.Decision.TargetID != "lse" {
+		t.Fatalf("expected the probed latency to make lse fastest, got %q", routed.Decision.TargetID)
+	}
+
+	if response := serveAs(server, nil, http.MethodPost, "/api/v1/routes", `{`+order+`,"venues":["nyse","nyse"]}`); response.Code != http.StatusBadRequest {
+		t.Fatalf("expected a repeated venue to be refused, got %d %s", response.Code, response.Body)
+	}
+	if response := serveAs(server, nil, http.MethodPost, "/api/v1/routes", `{`+order+`,"venues":["cme"]}`); response.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown venue to be refused, got %d %s", response.Code, response.Body)
+	}
+}
@@ -0,0 +1,336 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrMalformedToken   = errors.New("jwtauth: malformed token")
+	ErrUnsupportedAlg   = errors.New("jwtauth: unsupported signing algorithm")
+	ErrUnknownKey       = errors.New("jwtauth: no matching signing key")
+	ErrInvalidSignature = errors.New("jwtauth: invalid signature")
+	ErrExpired          = errors.New("jwtauth: token expired")
+	ErrNotYetValid      = errors.New("jwtauth: token not yet valid")
+	ErrInvalidClaims    = errors.New("jwtauth: invalid claims")
+)
+
+// refreshInterval bounds how often an unknown kid triggers a key reload, so
+// tokens with made-up key IDs cannot hammer the JWKS source.
+const refreshInterval = 30 * time.Second
+
+// Claims are the registered, scope and tenant claims the API relies on.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	Scopes    []string
+	Tenant    string
+}
+
+// HasScope reports whether the token was granted scope.
+func (c Claims) HasScope(scope string) bool {
+	for _, granted := range c.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type Config struct {
+	// JWKS is a file path or an http(s) URL serving the key set.
+	JWKS string
+	// Issuer and Audience are checked when set.
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew on exp and nbf. Defaults to 30s.
+	Leeway time.Duration
+}
+
+// Verifier checks token signatures against a key set it reloads when a token
+// names a key it has not seen, so rotated keys are picked up without a
+// restart. It is safe for concurrent use.
+type Verifier struct {
+	config Config
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      *KeySet
+	fetchedAt time.Time
+	// reloading is closed once the reload in flight, if any, has finished.
+	reloading chan struct{}
+}
+
+// NewVerifier loads the key set once up front so misconfiguration fails at
+// startup rather than on the first request.
+func NewVerifier(ctx context.Context, config Config) (*Verifier, error) {
+	if config.JWKS == "" {
+		return nil, errors.New("jwtauth: jwks source is required")
+	}
+	if config.Leeway <= 0 {
+		config.Leeway = 30 * time.Second
+	}
+	verifier := &Verifier{config: config, client: &http.Client{Timeout: 5 * time.Second}}
+	keys, err := verifier.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	verifier.keys = keys
+	verifier.fetchedAt = time.Now()
+	return verifier, nil
+}
+
+// Verify validates token at now and returns its claims.
+func (v *Verifier) Verify(ctx context.Context, token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrMalformedToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, err
+	}
+	if header.Alg != "RS256" && header.Alg != "ES256" {
+		return Claims{}, fmt.Errorf("%w: %q", ErrUnsupportedAlg, header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, ErrMalformedToken
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	keys := v.keysFor(ctx, header.Kid, now)
+	if len(keys) == 0 {
+		return Claims{}, ErrUnknownKey
+	}
+	verified := false
+	for _, key := range keys {
+		if verifySignature(header.Alg, key, digest[:], signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return Claims{}, ErrInvalidSignature
+	}
+
+	var raw rawClaims
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return Claims{}, err
+	}
+	claims, err := raw.claims()
+	if err != nil {
+		return Claims{}, err
+	}
+	return claims, v.validate(claims, now)
+}
+
+func (v *Verifier) validate(claims Claims, now time.Time) error {
+	if claims.ExpiresAt.IsZero() {
+		return fmt.Errorf("%w: exp is required", ErrInvalidClaims)
+	}
+	if !now.Before(claims.ExpiresAt.Add(v.config.Leeway)) {
+		return ErrExpired
+	}
+	if !claims.NotBefore.IsZero() && now.Add(v.config.Leeway).Before(claims.NotBefore) {
+		return ErrNotYetValid
+	}
+	if claims.Subject == "" {
+		return fmt.Errorf("%w: sub is required", ErrInvalidClaims)
+	}
+	if v.config.Issuer != "" && claims.Issuer != v.config.Issuer {
+		return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidClaims, claims.Issuer)
+	}
+	if v.config.Audience != "" {
+		for _, audience := range claims.Audience {
+			if audience == v.config.Audience {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: audience does not include %q", ErrInvalidClaims, v.config.Audience)
+	}
+	return nil
+}
+
+// keysFor returns the candidate keys for kid, reloading the key set first if
+// kid is unknown and the last reload is old enough. Callers needing a reload
+// already in flight wait for it, or for ctx, instead of starting another;
+// the lock is never held while fetching, so known keys verify meanwhile.
+func (v *Verifier) keysFor(ctx context.Context, kid string, now time.Time) []crypto.PublicKey {
+	v.mu.Lock()
+	if keys := v.keys.candidates(kid); len(keys) > 0 {
+		v.mu.Unlock()
+		return keys
+	}
+	reloading := v.reloading
+	if reloading == nil {
+		if now.Sub(v.fetchedAt) < refreshInterval {
+			v.mu.Unlock()
+			return nil
+		}
+		v.fetchedAt = now
+		reloading = make(chan struct{})
+		v.reloading = reloading
+		go v.reload(reloading)
+	}
+	v.mu.Unlock()
+
+	select {
+	case <-reloading:
+	case <-ctx.Done():
+		return nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.keys.candidates(kid)
+}
+
+// reload fetches the key set detached from the request that triggered it,
+// so that request giving up does not fail the others waiting, and swaps it
+// in. A failed fetch keeps the current keys.
+func (v *Verifier) reload(done chan struct{}) {
+	keys, err := v.load(context.Background())
+	v.mu.Lock()
+	if err == nil {
+		v.keys = keys
+	}
+	v.reloading = nil
+	v.mu.Unlock()
+	close(done)
+}
+
+func (v *Verifier) load(ctx context.Context) (*KeySet, error) {
+	source := v.config.JWKS
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("jwtauth: read jwks: %w", err)
+		}
+		return ParseJWKS(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("jwtauth: fetch jwks: %w", err)
+	}
+	response, err := v.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("jwtauth: fetch jwks: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwtauth: fetch jwks: status %d", response.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("jwtauth: fetch jwks: %w", err)
+	}
+	return ParseJWKS(data)
+}
+
+func verifySignature(alg string, key crypto.PublicKey, digest, signature []byte) bool {
+	switch alg {
+	case "RS256":
+		public, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(public, crypto.SHA256, digest, signature) == nil
+	case "ES256":
+		public, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(public, digest, r, s)
+	}
+	return false
+}
+
+func decodeSegment(segment string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformedToken
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return ErrMalformedToken
+	}
+	return nil
+}
+
+// rawClaims mirrors the JSON payload. aud may be a string or an array, and
+// scopes arrive either as a space separated "scope" string (OAuth 2) or as
+// an "scp" array.
+type rawClaims struct {
+	Sub    string          `json:"sub"`
+	Iss    string          `json:"iss"`
+	Aud    json.RawMessage `json:"aud"`
+	Exp    *float64        `json:"exp"`
+	Nbf    *float64        `json:"nbf"`
+	Scope  string          `json:"scope"`
+	Scp    json.RawMessage `json:"scp"`
+	Tenant string          `json:"tenant"`
+}
+
+func (r rawClaims) claims() (Claims, error) {
+	claims := Claims{
+		Subject: r.Sub,
+		Issuer:  r.Iss,
+		Scopes:  strings.Fields(r.Scope),
+		Tenant:  r.Tenant,
+	}
+	var err error
+	if claims.Audience, err = stringOrList(r.Aud); err != nil {
+		return Claims{}, fmt.Errorf("%w: aud", ErrInvalidClaims)
+	}
+	scp, err := stringOrList(r.Scp)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: scp", ErrInvalidClaims)
+	}
+	for _, scope := range scp {
+		claims.Scopes = append(claims.Scopes, strings.Fields(scope)...)
+	}
+	if r.Exp != nil {
+		claims.ExpiresAt = unixTime(*r.Exp)
+	}
+	if r.Nbf != nil {
+		claims.NotBefore = unixTime(*r.Nbf)
+	}
+	return claims, nil
+}
+
+func stringOrList(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var list []string
+	err := json.Unmarshal(raw, &list)
+	return list, err
+}
+
+func unixTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second))).UTC()
+}
@@ -0,0 +1,241 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func encode(v any) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	input := encode(map[string]string{"alg": "RS256", "kid": kid}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	input := encode(map[string]string{"alg": "ES256", "kid": kid}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func writeJWKS(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	data, _ := json.Marshal(map[string]any{"keys": keys})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifyRS256AndES256(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	path := writeJWKS(t, rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-1", &ecKey.PublicKey))
+
+	verifier, err := NewVerifier(context.Background(), Config{JWKS: path, Issuer: "https://sso.internal", Audience: "sor"})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	now := time.Now()
+	claims := map[string]any{
+		"sub":   "trader-7",
+		"iss":   "https://sso.internal",
+		"aud":   []string{"sor", "other"},
+		"exp":   now.Add(time.Minute).Unix(),
+		"scope": "routes:write audit:read",
+	}
+
+	for _, token := range []string{
+		signRS256(t, rsaKey, "rsa-1", claims),
+		signES256(t, ecKey, "ec-1", claims),
+	} {
+		got, err := verifier.Verify(context.Background(), token, now)
+		if err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+		if got.Subject != "trader-7" || !got.HasScope("routes:write") || !got.HasScope("audit:read") {
+			t.Fatalf("unexpected claims: %+v", got)
+		}
+	}
+}
+
+func TestVerifyRejectsBadTokens(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	path := writeJWKS(t, rsaJWK("rsa-1", &rsaKey.PublicKey))
+	verifier, err := NewVerifier(context.Background(), Config{JWKS: path, Audience: "sor"})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	now := time.Now()
+	valid := map[string]any{"sub": "trader-7", "aud": "sor", "exp": now.Add(time.Minute).Unix()}
+
+	unsigned := encode(map[string]string{"alg": "none"}) + "." + encode(valid) + "."
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"alg none", unsigned, ErrUnsupportedAlg},
+		{"wrong key", signRS256(t, otherKey, "rsa-1", valid), ErrInvalidSignature},
+		{"unknown kid", signRS256(t, rsaKey, "rsa-2", valid), ErrUnknownKey},
+		{"expired", signRS256(t, rsaKey, "rsa-1", map[string]any{"sub": "trader-7", "aud": "sor", "exp": now.Add(-time.Hour).Unix()}), ErrExpired},
+		{"wrong audience", signRS256(t, rsaKey, "rsa-1", map[string]any{"sub": "trader-7", "aud": "other", "exp": now.Add(time.Minute).Unix()}), ErrInvalidClaims},
+		{"no exp", signRS256(t, rsaKey, "rsa-1", map[string]any{"sub": "trader-7", "aud": "sor"}), ErrInvalidClaims},
+		{"garbage", "not-a-token", ErrMalformedToken},
+	}
+	for _, tt := range tests {
+		if _, err := verifier.Verify(context.Background(), tt.token, now); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestVerifierReloadsEndpointForRotatedKey(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	var rotated atomic.Bool
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		keys := []map[string]string{rsaJWK("old", &oldKey.PublicKey)}
+		if rotated.Load() {
+			keys = append(keys, ecJWK("new", &newKey.PublicKey))
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	defer server.Close()
+
+	verifier, err := NewVerifier(context.Background(), Config{JWKS: server.URL})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	rotated.Store(true)
+
+	now := time.Now()
+	token := signES256(t, newKey, "new", map[string]any{"sub": "svc", "exp": now.Add(time.Hour).Unix()})
+	if _, err := verifier.Verify(context.Background(), token, now); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected reload to be throttled right after startup, got %v", err)
+	}
+	later := now.Add(refreshInterval)
+	if _, err := verifier.Verify(context.Background(), token, later); err != nil {
+		t.Fatalf("expected rotated key to verify after reload: %v", err)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Fatalf("expected 2 fetches, got %d", got)
+	}
+}
+
+func TestVerifierKeepsVerifyingKnownKeysDuringReload(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{rsaJWK("known", &key.PublicKey)}})
+	}))
+	defer server.Close()
+	defer close(release)
+
+	verifier, err := NewVerifier(context.Background(), Config{JWKS: server.URL})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+
+	later := time.Now().Add(refreshInterval)
+	unknown := signRS256(t, key, "unknown", map[string]any{"sub": "svc", "exp": later.Add(time.Hour).Unix()})
+	waiters := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := verifier.Verify(context.Background(), unknown, later)
+			waiters <- err
+		}()
+	}
+
+	known := signRS256(t, key, "known", map[string]any{"sub": "svc", "exp": later.Add(time.Hour).Unix()})
+	verified := make(chan error, 1)
+	go func() {
+		_, err := verifier.Verify(context.Background(), known, later)
+		verified <- err
+	}()
+	select {
+	case err := <-verified:
+		if err != nil {
+			t.Fatalf("expected the known key to verify: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("a reload in flight blocked verification with a known key")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := verifier.Verify(ctx, unknown, later); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected a cancelled caller to stop waiting, got %v", err)
+	}
+
+	release <- struct{}{}
+	for i := 0; i < 2; i++ {
+		if err := <-waiters; !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("expected the unknown kid to stay unknown, got %v", err)
+		}
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Fatalf("expected concurrent callers to share one reload, got %d fetches", got)
+	}
+}
@@ -0,0 +1,377 @@
+// Package prober actively health checks registered venues so routing sees
+// measured availability and latency instead of only what clients claim.
+package prober
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/venue"
+)
+
+type Config struct {
+	// Interval between probe rounds. Defaults to 10s.
+	Interval time.Duration
+	// Timeout bounds one probe. Defaults to 2s.
+	Timeout time.Duration
+	// Window is how many recent samples availability and percentiles are
+	// computed over. Defaults to 30.
+	Window int
+	// AllowedNetworks are networks probes may reach although they are not
+	// public: loopback, private, link-local and similar addresses are refused
+	// otherwise, so whoever can configure a probe cannot use the engine to
+	// scan its own network.
+	AllowedNetworks []netip.Prefix
+}
+
+// ErrForbiddenTarget fails probes of addresses outside the networks probes
+// may reach.
+var ErrForbiddenTarget = errors.New("probe target is not a permitted address")
+
+// ParseNetworks parses a comma separated list of CIDRs or bare IPs, e.g.
+// "10.20.0.0/16,192.168.1.10".
+func ParseNetworks(raw string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			addr, err := netip.ParseAddr(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q: %w", part, err)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", part, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Permits reports whether probes may connect to addr: a public unicast
+// address, or any address inside AllowedNetworks.
+func (c Config) Permits(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range c.AllowedNetworks {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+// CheckTarget rejects a probe whose address is an IP literal, or localhost,
+// that probes may not reach. Host names are checked on every dial instead,
+// against whatever they resolve to at the time.
+func (c Config) CheckTarget(probe venue.Probe) error {
+	host := probe.Address
+	if probe.Type == venue.ProbeHTTP {
+		parsed, err := url.Parse(probe.Address)
+		if err != nil {
+			return err
+		}
+		host = parsed.Hostname()
+	} else if h, _, err := net.SplitHostPort(probe.Address); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		host = "127.0.0.1"
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !c.Permits(addr) {
+		return ErrForbiddenTarget
+	}
+	return nil
+}
+
+// Result summarizes the rolling window of one venue's probes.
+type Result struct {
+	Tenant       string    `json:"tenant,omitempty"`
+	VenueID      string    `json:"venueId"`
+	Type         string    `json:"type"`
+	Address      string    `json:"address"`
+	Samples      int       `json:"samples"`
+	Availability float64   `json:"availability"`
+	LatencyP50Ms int64     `json:"latencyP50Ms"`
+	LatencyP90Ms int64     `json:"latencyP90Ms"`
+	LatencyP99Ms int64     `json:"latencyP99Ms"`
+	LastSuccess  bool      `json:"lastSuccess"`
+	LastError    string    `json:"lastError,omitempty"`
+	LastProbeAt  time.Time `json:"lastProbeAt"`
+}
+
+type sample struct {
+	ok      bool
+	latency time.Duration
+}
+
+type key struct {
+	tenant  string
+	venueID string
+}
+
+// window is a ring buffer of a venue's most recent samples.
+type window struct {
+	probe   venue.Probe
+	samples []sample
+	next    int
+	last    Result
+}
+
+func (w *window) add(s sample, size int) {
+	if len(w.samples) < size {
+		w.samples = append(w.samples, s)
+		return
+	}
+	w.samples[w.next] = s
+	w.next = (w.next + 1) % size
+}
+
+func (w *window) summarize(tenant, venueID string, lastErr error, now time.Time) Result {
+	result := Result{
+		Tenant:      tenant,
+		VenueID:     venueID,
+		Type:        w.probe.Type,
+		Address:     w.probe.Address,
+		Samples:     len(w.samples),
+		LastSuccess: lastErr == nil,
+		LastProbeAt: now,
+	}
+	if lastErr != nil {
+		result.LastError = lastErr.Error()
+	}
+
+	latencies := make([]time.Duration, 0, len(w.samples))
+	for _, s := range w.samples {
+		if s.ok {
+			latencies = append(latencies, s.latency)
+		}
+	}
+	if len(w.samples) > 0 {
+		result.Availability = float64(len(latencies)) / float64(len(w.samples))
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.LatencyP50Ms = percentile(latencies, 50)
+	result.LatencyP90Ms = percentile(latencies, 90)
+	result.LatencyP99Ms = percentile(latencies, 99)
+	return result
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies in
+// milliseconds, rounded up so sub-millisecond probes do not read as free.
+func percentile(sorted []time.Duration, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return int64((sorted[rank-1] + time.Millisecond - 1) / time.Millisecond)
+}
+
+// Prober probes every venue that has a probe configured, once per interval.
+// It is safe for concurrent use.
+type Prober struct {
+	config  Config
+	venues  venue.Store
+	observe func(Result)
+	client  *http.Client
+	dialer  *net.Dialer
+
+	mu      sync.Mutex
+	windows map[key]*window
+}
+
+// New builds a prober over the venues in store. observe is called with each
+// venue's updated result after every probe, concurrently for different venues.
+func New(config Config, store venue.Store, observe func(Result)) *Prober {
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 2 * time.Second
+	}
+	if config.Window <= 0 {
+		config.Window = 30
+	}
+	p := &Prober{
+		config:  config,
+		venues:  store,
+		observe: observe,
+		windows: make(map[key]*window),
+	}
+	// Every connection, HTTP ones included, goes through the dialer, which
+	// checks the address a host name actually resolved to.
+	p.dialer = &net.Dialer{Timeout: config.Timeout, Control: p.control}
+	p.client = &http.Client{
+		Timeout:   config.Timeout,
+		Transport: &http.Transport{DialContext: p.dialer.DialContext, TLSHandshakeTimeout: config.Timeout},
+		// A redirect is already a sign of life; following it would
+		// probe a different host.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return p
+}
+
+func (p *Prober) control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !p.config.Permits(addrPort.Addr()) {
+		return ErrForbiddenTarget
+	}
+	return nil
+}
+
+// Run probes until ctx is done.
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		p.ProbeOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProbeOnce runs one round, probing all venues concurrently, and forgets
+// venues that were removed or lost their probe. A round whose venues cannot
+// be listed is skipped.
+func (p *Prober) ProbeOnce(ctx context.Context) {
+	type job struct {
+		key   key
+		probe venue.Probe
+	}
+	var jobs []job
+	err := p.venues.Scan(func(tenant string, registered venue.Venue) error {
+		if registered.Probe != nil {
+			jobs = append(jobs, job{key: key{tenant, registered.ID}, probe: *registered.Probe})
+		}
+		return nil
+	})
+	if err != nil {
+		// Without the full list of venues, pruning would forget every window.
+		log.Printf("prober: failed to list venues, skipping round: %v", err)
+		return
+	}
+
+	live := make(map[key]bool, len(jobs))
+	for _, j := range jobs {
+		live[j.key] = true
+	}
+	p.mu.Lock()
+	for k := range p.windows {
+		if !live[k] {
+			delete(p.windows, k)
+		}
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j job) {
+			defer wg.Done()
+			start := time.Now()
+			err := p.probe(ctx, j.probe)
+			p.record(j.key, j.probe, sample{ok: err == nil, latency: time.Since(start)}, err, time.Now())
+		}(j)
+	}
+	wg.Wait()
+}
+
+func (p *Prober) record(k key, probe venue.Probe, s sample, err error, now time.Time) {
+	p.mu.Lock()
+	w, ok := p.windows[k]
+	if !ok || w.probe != probe {
+		// A changed probe measures something else; start over.
+		w = &window{probe: probe}
+		p.windows[k] = w
+	}
+	w.add(s, p.config.Window)
+	w.last = w.summarize(k.tenant, k.venueID, err, now)
+	result := w.last
+	p.mu.Unlock()
+
+	if p.observe != nil {
+		p.observe(result)
+	}
+}
+
+// probe checks one venue. A refused target reports ErrForbiddenTarget alone,
+// without the address its host name resolved to.
+func (p *Prober) probe(ctx context.Context, probe venue.Probe) error {
+	err := p.check(ctx, probe)
+	if errors.Is(err, ErrForbiddenTarget) {
+		return ErrForbiddenTarget
+	}
+	return err
+}
+
+func (p *Prober) check(ctx context.Context, probe venue.Probe) error {
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+
+	switch probe.Type {
+	case venue.ProbeHTTP:
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, probe.Address, nil)
+		if err != nil {
+			return err
+		}
+		response, err := p.client.Do(request)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+		if response.StatusCode >= 400 {
+			return fmt.Errorf("status %d", response.StatusCode)
+		}
+		return nil
+	case venue.ProbeTCP:
+		conn, err := p.dialer.DialContext(ctx, "tcp", probe.Address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	return errors.New("unknown probe type " + probe.Type)
+}
+
+// Results returns the latest result of every probed venue of tenant, ordered
+// by venue ID.
+func (p *Prober) Results(tenant string) []Result {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	results := make([]Result, 0, len(p.windows))
+	for k, w := range p.windows {
+		if k.tenant == tenant {
+			results = append(results, w.last)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].VenueID < results[j].VenueID })
+	return results
+}
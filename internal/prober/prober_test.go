@@ -0,0 +1,170 @@
+package prober
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/venue"
+)
+
+func TestProbeOnceComputesRollingAvailability(t *testing.T) {
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := listener.Addr().String()
+	listener.Close()
+
+	store := venue.NewMemoryStore()
+	_ = store.Put("acme", venue.Venue{ID: "nyse", Probe: &venue.Probe{Type: venue.ProbeHTTP, Address: server.URL}})
+	_ = store.Put("acme", venue.Venue{ID: "down", Probe: &venue.Probe{Type: venue.ProbeTCP, Address: closedAddr}})
+	_ = store.Put("acme", venue.Venue{ID: "unprobed"})
+
+	var mu sync.Mutex
+	observed := make(map[string]Result)
+	loopback := []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+	prober := New(Config{Timeout: time.Second, Window: 4, AllowedNetworks: loopback}, store, func(result Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		observed[result.VenueID] = result
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		failing.Store(i == 3)
+		prober.ProbeOnce(ctx)
+	}
+
+	results := prober.Results("acme")
+	if len(results) != 2 || results[0].VenueID != "down" || results[1].VenueID != "nyse" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if down := results[0]; down.Availability != 0 || down.LastSuccess || down.LastError == "" {
+		t.Fatalf("expected closed port to be unavailable, got %+v", down)
+	}
+	if nyse := results[1]; nyse.Samples != 4 || nyse.Availability != 0.75 || nyse.LastSuccess || nyse.LatencyP50Ms < 1 {
+		t.Fatalf("expected 3 of 4 successful probes, got %+v", nyse)
+	}
+	if observed["nyse"].Availability != 0.75 {
+		t.Fatalf("expected observe to receive the latest result, got %+v", observed["nyse"])
+	}
+	if len(prober.Results("")) != 0 {
+		t.Fatal("expected results to be scoped to their tenant")
+	}
+
+	_, _ = store.Delete("acme", "down")
+	prober.ProbeOnce(ctx)
+	if results := prober.Results("acme"); len(results) != 1 || results[0].VenueID != "nyse" {
+		t.Fatalf("expected removed venue to be forgotten, got %+v", results)
+	}
+}
+
+// flakyStore fails Scan while failing is set.
+type flakyStore struct {
+	venue.Store
+	failing atomic.Bool
+}
+
+func (s *flakyStore) Scan(fn func(string, venue.Venue) error) error {
+	if s.failing.Load() {
+		return errors.New("database unavailable")
+	}
+	return s.Store.Scan(fn)
+}
+
+func TestProbeOnceKeepsWindowsWhenVenuesCannotBeListed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	store := &flakyStore{Store: venue.NewMemoryStore()}
+	_ = store.Put("", venue.Venue{ID: "nyse", Probe: &venue.Probe{Type: venue.ProbeHTTP, Address: server.URL}})
+	loopback := []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+	prober := New(Config{Timeout: time.Second, AllowedNetworks: loopback}, store, nil)
+
+	prober.ProbeOnce(context.Background())
+	store.failing.Store(true)
+	prober.ProbeOnce(context.Background())
+	if results := prober.Results(""); len(results) != 1 || results[0].Samples != 1 {
+		t.Fatalf("expected a failed listing to skip the round and keep the window, got %+v", results)
+	}
+}
+
+func TestProbesRefuseNonPublicAddresses(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	store := venue.NewMemoryStore()
+	_ = store.Put("", venue.Venue{ID: "http", Probe: &venue.Probe{Type: venue.ProbeHTTP, Address: server.URL}})
+	_ = store.Put("", venue.Venue{ID: "tcp", Probe: &venue.Probe{Type: venue.ProbeTCP, Address: server.Listener.Addr().String()}})
+	prober := New(Config{Timeout: time.Second}, store, nil)
+	prober.ProbeOnce(context.Background())
+
+	for _, result := range prober.Results("") {
+		if result.LastSuccess || result.LastError != ErrForbiddenTarget.Error() {
+			t.Fatalf("expected %s to be refused, got %+v", result.VenueID, result)
+		}
+	}
+	if hits.Load() != 0 {
+		t.Fatal("a refused probe reached the target")
+	}
+
+	cases := []struct {
+		address string
+		allowed bool
+	}{
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://localhost:6379/", false},
+		{"http://[::1]/", false},
+		{"http://10.1.2.3/", false},
+		{"http://venue.example.com/health", true},
+		{"http://203.0.113.10/health", true},
+	}
+	for _, tc := range cases {
+		err := Config{}.CheckTarget(venue.Probe{Type: venue.ProbeHTTP, Address: tc.address})
+		if (err == nil) != tc.allowed {
+			t.Fatalf("CheckTarget(%s) = %v", tc.address, err)
+		}
+	}
+	if err := (Config{}).CheckTarget(venue.Probe{Type: venue.ProbeTCP, Address: "192.168.0.5:5432"}); err == nil {
+		t.Fatal("expected a private tcp target to be refused")
+	}
+	allowed := Config{AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+	if err := allowed.CheckTarget(venue.Probe{Type: venue.ProbeTCP, Address: "10.1.2.3:443"}); err != nil {
+		t.Fatalf("expected an allowed network to be permitted, got %v", err)
+	}
+}
+
+func TestPercentileNearestRank(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 10; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	if got := percentile(sorted, 50); got != 5 {
+		t.Fatalf("p50 = %d", got)
+	}
+	if got := percentile(sorted, 99); got != 10 {
+		t.Fatalf("p99 = %d", got)
+	}
+	if got := percentile([]time.Duration{300 * time.Microsecond}, 50); got != 1 {
+		t.Fatalf("expected sub-millisecond latency to round up, got %d", got)
+	}
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+    "container/list"
+    "time"
+)
+
+// DefaultMaxKeys bounds how many clients a limiter tracks at once.
+const DefaultMaxKeys = 100_000
+
+// Stats reports how many keys a limiter tracks and how many it has dropped,
+// either because they sat idle long enough to have no effect on limiting or
+// because the key limit forced out the least recently used one.
+type Stats struct {
+    Keys              int
+    ExpiredEvictions  uint64
+    CapacityEvictions uint64
+}
+
+// StatsReporter is implemented by limiters that keep per-key state locally.
+type StatsReporter interface {
+    Stats() Stats
+}
+
+// keyStore is a bounded LRU of per-key limiter state. Entries idle for
+// longer than idle are swept from the cold end on every access, which keeps
+// eviction amortized without a background goroutine. It is not safe for
+// concurrent use; limiters guard it with their own mutex.
+type keyStore[T any] struct {
+    maxKeys int
+    idle    time.Duration
+    order   *list.List
+    items   map[string]*list.Element
+    stats   Stats
+}
+
+type keyEntry[T any] struct {
+    key     string
+    value   T
+    touched time.Time
+}
+
+func newKeyStore[T any](maxKeys int, idle time.Duration) *keyStore[T] {
+    if maxKeys <= 0 {
+        maxKeys = DefaultMaxKeys
+    }
+    return &keyStore[T]{
+        maxKeys: maxKeys,
+        idle:    idle,
+        order:   list.New(),
+        items:   make(map[string]*list.Element),
+    }
+}
+
+// get returns the state for key, creating it with create when missing, and
+// marks it as most recently used.
+func (s *keyStore[T]) get(key string, now time.Time, create func() T) T {
+    s.sweep(now)
+
+    if element, ok := s.items[key]; ok {
+        entry := element.Value.(*keyEntry[T])
+        entry.touched = now
+        s.order.MoveToFront(element)
+        return entry.value
+    }
+
+    for len(s.items) >= s.maxKeys {
+        s.remove(s.order.Back())
+        s.stats.CapacityEvictions++
+    }
+    entry := &keyEntry[T]{key: key, value: create(), touched: now}
+    s.items[key] = s.order.PushFront(entry)
+    return entry.value
+}
+
+func (s *keyStore[T]) sweep(now time.Time) {
+    for element := s.order.Back(); element != nil; element = s.order.Back() {
+        if now.Sub(element.Value.(*keyEntry[T]).touched) <= s.idle {
+            return
+        }
+        s.remove(element)
+        s.stats.ExpiredEvictions++
+    }
+}
+
+func (s *keyStore[T]) remove(element *list.Element) {
+    s.order.Remove(element)
+    delete(s.items, element.Value.(*keyEntry[T]).key)
+}
+
+func (s *keyStore[T]) snapshot() Stats {
+    stats := s.stats
+    stats.Keys = len(s.items)
+    return stats
+}
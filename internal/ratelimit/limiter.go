@@ -1,15 +1,73 @@
 package ratelimit
 
 import (
+    "errors"
     "sync"
     "time"
 )
 
-type Limiter struct {
+const (
+    AlgorithmFixedWindow   = "fixed-window"
+    AlgorithmTokenBucket   = "token-bucket"
+    AlgorithmSlidingLog    = "sliding-log"
+    AlgorithmSlidingWindow = "sliding-window"
+)
+
+var ErrUnknownAlgorithm = errors.New("unknown rate limit algorithm")
+
+// Limiter decides whether the caller identified by key may make another
+// request at now. Implementations are safe for concurrent use.
+type Limiter interface {
+    Allow(key string, now time.Time) Result
+}
+
+// Result is the outcome of one Allow call together with the quota state
+// clients need to pace themselves.
+type Result struct {
+    Allowed   bool
+    Limit     int
+    Remaining int
+    // ResetAfter is how long until the key's quota is fully available again.
+    ResetAfter time.Duration
+    // RetryAfter is how long a rejected caller should wait before the next
+    // request can succeed. It is zero when Allowed is true.
+    RetryAfter time.Duration
+}
+
+// Config describes a limiter allowing MaxRequests per Window for each key.
+// MaxKeys bounds the number of keys tracked at once and defaults to
+// DefaultMaxKeys.
+type Config struct {
+    Algorithm   string
+    MaxRequests int
+    Window      time.Duration
+    MaxKeys     int
+}
+
+// New builds the limiter named by config.Algorithm. An empty algorithm
+// selects the fixed window.
+func New(config Config) (Limiter, error) {
+    switch config.Algorithm {
+    case "", AlgorithmFixedWindow:
+        return NewFixedWindow(config), nil
+    case AlgorithmTokenBucket:
+        return NewTokenBucket(config), nil
+    case AlgorithmSlidingLog:
+        return NewSlidingLog(config), nil
+    case AlgorithmSlidingWindow:
+        return NewSlidingWindow(config), nil
+    }
+    return nil, ErrUnknownAlgorithm
+}
+
+// FixedWindow counts requests in consecutive windows that start at a key's
+// first request. It is cheap but admits up to twice maxRequests across a
+// window boundary.
+type FixedWindow struct {
     mu          sync.Mutex
     window      time.Duration
     maxRequests int
-    buckets     map[string]*bucket
+    buckets     *keyStore[*bucket]
 }
 
 type bucket struct {
@@ -17,34 +75,44 @@ type bucket struct {
     windowStart time.Time
 }
 
-func NewLimiter(maxRequests int, window time.Duration) *Limiter {
-    return &Limiter{
-        window:      window,
-        maxRequests: maxRequests,
-        buckets:     make(map[string]*bucket),
+func NewFixedWindow(config Config) *FixedWindow {
+    return &FixedWindow{
+        window:      config.Window,
+        maxRequests: config.MaxRequests,
+        buckets:     newKeyStore[*bucket](config.MaxKeys, config.Window),
     }
 }
 
-func (l *Limiter) Allow(key string, now time.Time) bool {
+func (l *FixedWindow) Allow(key string, now time.Time) Result {
     l.mu.Lock()
     defer l.mu.Unlock()
 
-    current, ok := l.buckets[key]
-    if !ok {
-        l.buckets[key] = &bucket{count: 1, windowStart: now}
-        return true
-    }
+    current := l.buckets.get(key, now, func() *bucket {
+        return &bucket{windowStart: now}
+    })
 
     if now.Sub(current.windowStart) >= l.window {
         current.windowStart = now
-        current.count = 1
-        return true
+        current.count = 0
     }
 
+    result := Result{
+        Limit:      l.maxRequests,
+        ResetAfter: current.windowStart.Add(l.window).Sub(now),
+    }
     if current.count >= l.maxRequests {
-        return false
+        result.RetryAfter = result.ResetAfter
+        return result
     }
 
     current.count++
-    return true
+    result.Allowed = true
+    result.Remaining = l.maxRequests - current.count
+    return result
+}
+
+func (l *FixedWindow) Stats() Stats {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.buckets.snapshot()
 }
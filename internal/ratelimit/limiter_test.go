@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSmoothLimitersRejectBoundaryBursts(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, algorithm := range []string{AlgorithmTokenBucket, AlgorithmSlidingLog, AlgorithmSlidingWindow} {
+		limiter, err := New(Config{Algorithm: algorithm, MaxRequests: 10, Window: time.Minute})
+		if err != nil {
+			t.Fatalf("%s: %v", algorithm, err)
+		}
+
+		// Use the whole quota at the end of one window, then try again right
+		// after the boundary. A fixed window would admit another 10.
+		late := start.Add(59 * time.Second)
+		for i := 0; i < 10; i++ {
+			if !limiter.Allow("client", late).Allowed {
+				t.Fatalf("%s: request %d should be allowed", algorithm, i)
+			}
+		}
+		admitted := 0
+		for i := 0; i < 10; i++ {
+			if limiter.Allow("client", start.Add(61*time.Second)).Allowed {
+				admitted++
+			}
+		}
+		if admitted > 1 {
+			t.Fatalf("%s: admitted %d requests right after the boundary", algorithm, admitted)
+		}
+		if !limiter.Allow("other", late).Allowed {
+			t.Fatalf("%s: keys must be limited independently", algorithm)
+		}
+	}
+}
+
+func TestTokenBucketRefillsGradually(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewTokenBucket(Config{MaxRequests: 60, Window: time.Minute})
+	for i := 0; i < 60; i++ {
+		limiter.Allow("client", now)
+	}
+	if limiter.Allow("client", now).Allowed {
+		t.Fatalf("bucket should be empty")
+	}
+	if !limiter.Allow("client", now.Add(time.Second)).Allowed {
+		t.Fatalf("one token should refill after a second")
+	}
+	if limiter.Allow("client", now.Add(time.Second)).Allowed {
+		t.Fatalf("only one token should have refilled")
+	}
+}
+
+func TestNewRejectsUnknownAlgorithm(t *testing.T) {
+	if _, err := New(Config{Algorithm: "leaky", MaxRequests: 1, Window: time.Second}); err != ErrUnknownAlgorithm {
+		t.Fatalf("expected ErrUnknownAlgorithm, got %v", err)
+	}
+}
+
+func TestLimiterEvictsIdleAndLeastRecentlyUsedKeys(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewFixedWindow(Config{MaxRequests: 5, Window: time.Minute, MaxKeys: 2})
+
+	limiter.Allow("a", now)
+	limiter.Allow("b", now.Add(time.Second))
+	limiter.Allow("a", now.Add(2*time.Second))
+	limiter.Allow("c", now.Add(3*time.Second))
+
+	stats := limiter.Stats()
+	if stats.Keys != 2 || stats.CapacityEvictions != 1 {
+		t.Fatalf("expected b to be evicted for capacity, got %+v", stats)
+	}
+
+	limiter.Allow("d", now.Add(2*time.Minute))
+	stats = limiter.Stats()
+	if stats.Keys != 1 || stats.ExpiredEvictions != 2 {
+		t.Fatalf("expected idle keys to be swept, got %+v", stats)
+	}
+}
+
+func TestAllowReportsQuotaState(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 30, 0, time.UTC)
+	limiter := NewFixedWindow(Config{MaxRequests: 2, Window: time.Minute})
+
+	first := limiter.Allow("client", now)
+	if !first.Allowed || first.Limit != 2 || first.Remaining != 1 || first.ResetAfter != time.Minute {
+		t.Fatalf("unexpected first result: %+v", first)
+	}
+	limiter.Allow("client", now.Add(10*time.Second))
+	denied := limiter.Allow("client", now.Add(20*time.Second))
+	if denied.Allowed || denied.Remaining != 0 || denied.RetryAfter != 40*time.Second {
+		t.Fatalf("unexpected denied result: %+v", denied)
+	}
+
+	bucket := NewTokenBucket(Config{MaxRequests: 60, Window: time.Minute})
+	for i := 0; i < 60; i++ {
+		bucket.Allow("client", now)
+	}
+	empty := bucket.Allow("client", now)
+	if empty.Allowed || empty.RetryAfter != time.Second || empty.ResetAfter != time.Minute {
+		t.Fatalf("unexpected empty bucket result: %+v", empty)
+	}
+}
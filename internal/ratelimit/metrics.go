@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+    "context"
+
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "ratelimit"
+
+// RegisterMetrics publishes the tracked key count and eviction totals of
+// reporter through the global OpenTelemetry meter provider.
+func RegisterMetrics(reporter StatsReporter) error {
+    meter := otel.Meter(meterName)
+
+    keys, err := meter.Int64ObservableGauge("ratelimit.keys",
+        metric.WithDescription("Client keys currently tracked by the rate limiter"),
+    )
+    if err != nil {
+        return err
+    }
+    evictions, err := meter.Int64ObservableCounter("ratelimit.evictions",
+        metric.WithDescription("Client keys dropped by the rate limiter"),
+    )
+    if err != nil {
+        return err
+    }
+
+    expired := metric.WithAttributes(attribute.String("reason", "expired"))
+    capacity := metric.WithAttributes(attribute.String("reason", "capacity"))
+    _, err = meter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+        stats := reporter.Stats()
+        observer.ObserveInt64(keys, int64(stats.Keys))
+        observer.ObserveInt64(evictions, int64(stats.ExpiredEvictions), expired)
+        observer.ObserveInt64(evictions, int64(stats.CapacityEvictions), capacity)
+        return nil
+    }, keys, evictions)
+    return err
+}
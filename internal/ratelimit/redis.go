@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/GuilhermeSoares009/smart-order-routing-engine/internal/resp"
+)
+
+const (
+    redisKeyPrefix    = "sor:ratelimit:"
+    redisTimeout      = 50 * time.Millisecond
+    redisRetryBackoff = 5 * time.Second
+)
+
+// ErrRedisAlgorithm is returned by NewRedis for any algorithm but the token
+// bucket, the only one implemented in Redis.
+var ErrRedisAlgorithm = errors.New("redis rate limiting only supports the " + AlgorithmTokenBucket + " algorithm")
+
+// TokenBucketScript refills and takes a token atomically inside Redis.
+// KEYS[1] is the bucket; ARGV holds the capacity, the refill rate in tokens
+// per millisecond and the key TTL. Time is read from the Redis server, so
+// replicas with skewed clocks still refill buckets consistently. It returns
+// {allowed, remaining tokens}.
+const TokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = capacity
+  ts = now
+end
+if now > ts then
+  tokens = math.min(capacity, tokens + (now - ts) * rate)
+  ts = now
+end
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {allowed, tostring(tokens)}
+`
+
+// Redis is a token bucket limiter whose buckets live in Redis, so every
+// replica draws from the same quota. When Redis cannot be reached it falls
+// back to a local token bucket and retries Redis after a short backoff.
+type Redis struct {
+    client   *resp.Client
+    capacity int
+    window   time.Duration
+    fallback *TokenBucket
+
+    mu        sync.Mutex
+    downUntil time.Time
+}
+
+// NewRedis builds a Redis limiter for config, whose algorithm must be empty
+// or AlgorithmTokenBucket.
+func NewRedis(client *resp.Client, config Config) (*Redis, error) {
+    if config.Algorithm != "" && config.Algorithm != AlgorithmTokenBucket {
+        return nil, ErrRedisAlgorithm
+    }
+    return &Redis{
+        client:   client,
+        capacity: config.MaxRequests,
+        window:   config.Window,
+        fallback: NewTokenBucket(config),
+    }, nil
+}
+
+func (l *Redis) Allow(key string, now time.Time) Result {
+    if l.degraded(now) {
+        return l.fallback.Allow(key, now)
+    }
+
+    result, err := l.allowRemote(key, now)
+    if err != nil {
+        l.markDown(now)
+        return l.fallback.Allow(key, now)
+    }
+    return result
+}
+
+// Stats reports on the local fallback buckets.
+func (l *Redis) Stats() Stats {
+    return l.fallback.Stats()
+}
+
+// Degraded reports whether decisions are currently made locally.
+func (l *Redis) Degraded(now time.Time) bool {
+    return l.degraded(now)
+}
+
+func (l *Redis) allowRemote(key string, now time.Time) (Result, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+    defer cancel()
+
+    ratePerMs := float64(l.capacity) / float64(l.window.Milliseconds())
+    reply, err := l.client.EvalScript(ctx, TokenBucketScript,
+        []string{redisKeyPrefix + key},
+        strconv.Itoa(l.capacity),
+        strconv.FormatFloat(ratePerMs, 'g', -1, 64),
+        strconv.FormatInt(l.window.Milliseconds(), 10),
+    )
+    if err != nil {
+        return Result{}, err
+    }
+    items, ok := reply.([]any)
+    if !ok || len(items) != 2 {
+        return Result{}, fmt.Errorf("ratelimit: unexpected script reply %v", reply)
+    }
+    allowed, err := resp.Int64(items[0], nil)
+    if err != nil {
+        return Result{}, err
+    }
+    rawTokens, err := resp.String(items[1], nil)
+    if err != nil {
+        return Result{}, err
+    }
+    available, err := strconv.ParseFloat(rawTokens, 64)
+    if err != nil {
+        return Result{}, err
+    }
+    return bucketResult(allowed == 1, float64(l.capacity), available, ratePerMs*1000), nil
+}
+
+func (l *Redis) degraded(now time.Time) bool {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return now.Before(l.downUntil)
+}
+
+func (l *Redis) markDown(now time.Time) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.downUntil = now.Add(redisRetryBackoff)
+}
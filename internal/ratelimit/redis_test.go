@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/resp"
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/resp/resptest"
+)
+
+// emulateTokenBucket mirrors TokenBucketScript for the RESP stand-in, which
+// cannot run Lua.
+func emulateTokenBucket(s *resptest.Server, keys, args []string) any {
+	capacity, _ := strconv.ParseFloat(args[0], 64)
+	rate, _ := strconv.ParseFloat(args[1], 64)
+	ttl, _ := strconv.ParseInt(args[2], 10, 64)
+	now := float64(s.Now().UnixMilli())
+
+	tokens, ts := capacity, now
+	storedTokens, hasTokens := s.HashGet(keys[0], "tokens")
+	storedTs, hasTs := s.HashGet(keys[0], "ts")
+	if hasTokens && hasTs {
+		tokens, _ = strconv.ParseFloat(storedTokens, 64)
+		ts, _ = strconv.ParseFloat(storedTs, 64)
+	}
+	if now > ts {
+		tokens = math.Min(capacity, tokens+(now-ts)*rate)
+		ts = now
+	}
+	allowed := int64(0)
+	if tokens >= 1 {
+		tokens--
+		allowed = 1
+	}
+	s.HashSet(keys[0], "tokens", strconv.FormatFloat(tokens, 'g', -1, 64))
+	s.HashSet(keys[0], "ts", strconv.FormatFloat(ts, 'g', -1, 64))
+	s.Expire(keys[0], time.Duration(ttl)*time.Millisecond)
+	return []any{allowed, strconv.FormatFloat(tokens, 'g', -1, 64)}
+}
+
+func TestRedisLimiterSharesQuotaAndFallsBackLocally(t *testing.T) {
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("start stand-in: %v", err)
+	}
+	server.RegisterScript(TokenBucketScript, emulateTokenBucket)
+
+	config := Config{MaxRequests: 3, Window: time.Minute}
+	replicaA, err := NewRedis(resp.NewClient(server.Addr(), resp.Options{}), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replicaB, _ := NewRedis(resp.NewClient(server.Addr(), resp.Options{}), config)
+
+	now := time.Now()
+	allowed := 0
+	for i := 0; i < 3; i++ {
+		for _, replica := range []*Redis{replicaA, replicaB} {
+			if replica.Allow("client", now).Allowed {
+				allowed++
+			}
+		}
+	}
+	if allowed != 3 {
+		t.Fatalf("replicas should share one quota of 3, allowed %d", allowed)
+	}
+	if replicaA.Degraded(now) {
+		t.Fatalf("limiter should not be degraded while redis is up")
+	}
+	checkBucketLayout(t, resp.NewClient(server.Addr(), resp.Options{}), "client")
+
+	server.Close()
+
+	if !replicaA.Allow("client", now).Allowed {
+		t.Fatalf("local fallback should start with a fresh bucket")
+	}
+	if !replicaA.Degraded(now) {
+		t.Fatalf("limiter should report degraded mode after redis failed")
+	}
+}
+
+func TestNewRedisRejectsAlgorithmsOtherThanTokenBucket(t *testing.T) {
+	client := resp.NewClient("127.0.0.1:0", resp.Options{})
+	if _, err := NewRedis(client, Config{Algorithm: AlgorithmSlidingLog, MaxRequests: 1, Window: time.Second}); err != ErrRedisAlgorithm {
+		t.Fatalf("expected ErrRedisAlgorithm, got %v", err)
+	}
+	if _, err := NewRedis(client, Config{Algorithm: AlgorithmTokenBucket, MaxRequests: 1, Window: time.Second}); err != nil {
+		t.Fatalf("expected the token bucket to be accepted, got %v", err)
+	}
+}
+
+// checkBucketLayout asserts key's bucket is the hash TokenBucketScript keeps,
+// with a TTL, so the emulation cannot drift from the script unnoticed.
+func checkBucketLayout(t *testing.T, client *resp.Client, key string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	reply, err := client.Do(ctx, "HMGET", redisKeyPrefix+key, "tokens", "ts")
+	if err != nil {
+		t.Fatalf("HMGET: %v", err)
+	}
+	fields, ok := reply.([]any)
+	if !ok || len(fields) != 2 || fields[0] == nil || fields[1] == nil {
+		t.Fatalf("expected a tokens and ts hash, got %v", reply)
+	}
+	if ttl, err := resp.Int64(client.Do(ctx, "PTTL", redisKeyPrefix+key)); err != nil || ttl <= 0 {
+		t.Fatalf("expected the bucket to expire, got PTTL %d (%v)", ttl, err)
+	}
+}
+
+// TestTokenBucketScriptOnRedis runs TokenBucketScript on the Redis at
+// REDIS_ADDR, which the stand-in only emulates. It is skipped when none is
+// reachable.
+func TestTokenBucketScriptOnRedis(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	client := resp.NewClient(addr, resp.Options{})
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := client.Do(ctx, "PING"); err != nil {
+		t.Skipf("redis at %s is unreachable: %v", addr, err)
+	}
+
+	key := "test-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	defer client.Do(context.Background(), "DEL", redisKeyPrefix+key)
+	limiter, err := NewRedis(client, Config{MaxRequests: 3, Window: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if result := limiter.Allow(key, now); !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("request %d: expected to be allowed with %d left, got %+v", i, 2-i, result)
+		}
+	}
+	if result := limiter.Allow(key, now); result.Allowed || result.RetryAfter <= 0 {
+		t.Fatalf("expected the fourth request to be limited, got %+v", result)
+	}
+	if limiter.Degraded(time.Now()) {
+		t.Fatalf("expected redis to stay up")
+	}
+	checkBucketLayout(t, client, key)
+}
@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+    "sync"
+    "time"
+)
+
+// SlidingLog remembers the time of every admitted request and allows a new
+// one only while fewer than maxRequests fall inside the trailing window. It
+// is exact but keeps up to maxRequests timestamps per key.
+type SlidingLog struct {
+    mu          sync.Mutex
+    window      time.Duration
+    maxRequests int
+    logs        *keyStore[*requestLog]
+}
+
+type requestLog struct {
+    times []time.Time
+}
+
+func NewSlidingLog(config Config) *SlidingLog {
+    return &SlidingLog{
+        window:      config.Window,
+        maxRequests: config.MaxRequests,
+        logs:        newKeyStore[*requestLog](config.MaxKeys, config.Window),
+    }
+}
+
+func (l *SlidingLog) Allow(key string, now time.Time) Result {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    history := l.logs.get(key, now, func() *requestLog { return &requestLog{} })
+    cutoff := now.Add(-l.window)
+    expired := 0
+    for expired < len(history.times) && !history.times[expired].After(cutoff) {
+        expired++
+    }
+    history.times = history.times[expired:]
+
+    result := Result{Limit: l.maxRequests}
+    if len(history.times) >= l.maxRequests {
+        result.ResetAfter = history.times[len(history.times)-1].Add(l.window).Sub(now)
+        result.RetryAfter = history.times[0].Add(l.window).Sub(now)
+        return result
+    }
+    history.times = append(history.times, now)
+    result.Allowed = true
+    result.Remaining = l.maxRequests - len(history.times)
+    result.ResetAfter = l.window
+    return result
+}
+
+func (l *SlidingLog) Stats() Stats {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.logs.snapshot()
+}
+
+// SlidingWindow approximates a sliding log with two counters per key: the
+// current fixed window and the previous one, weighted by how much of the
+// previous window still overlaps the trailing window.
+type SlidingWindow struct {
+    mu          sync.Mutex
+    window      time.Duration
+    maxRequests int
+    counters    *keyStore[*windowCounter]
+}
+
+type windowCounter struct {
+    windowStart time.Time
+    current     int
+    previous    int
+}
+
+func NewSlidingWindow(config Config) *SlidingWindow {
+    return &SlidingWindow{
+        window:      config.Window,
+        maxRequests: config.MaxRequests,
+        // The previous window's count still matters for one more window.
+        counters: newKeyStore[*windowCounter](config.MaxKeys, 2*config.Window),
+    }
+}
+
+func (l *SlidingWindow) Allow(key string, now time.Time) Result {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    start := now.Truncate(l.window)
+    counter := l.counters.get(key, now, func() *windowCounter {
+        return &windowCounter{windowStart: start}
+    })
+
+    switch elapsed := start.Sub(counter.windowStart); {
+    case elapsed == l.window:
+        counter.previous = counter.current
+        counter.current = 0
+        counter.windowStart = start
+    case elapsed > l.window:
+        counter.previous = 0
+        counter.current = 0
+        counter.windowStart = start
+    }
+
+    overlap := 1 - float64(now.Sub(start))/float64(l.window)
+    estimate := float64(counter.previous)*overlap + float64(counter.current)
+    windowEnd := start.Add(l.window)
+
+    result := Result{Limit: l.maxRequests}
+    if estimate+1 > float64(l.maxRequests) {
+        result.ResetAfter = windowEnd.Add(l.window).Sub(now)
+        result.RetryAfter = l.retryAfter(counter, start, now)
+        return result
+    }
+    counter.current++
+    result.Allowed = true
+    result.Remaining = max(0, int(float64(l.maxRequests)-estimate-1))
+    result.ResetAfter = windowEnd.Add(l.window).Sub(now)
+    if counter.previous == 0 {
+        result.ResetAfter = windowEnd.Sub(now)
+    }
+    return result
+}
+
+// retryAfter finds when the weighted estimate first leaves room for one more
+// request, either later in the current window as the previous window's
+// weight decays or during the next window.
+func (l *SlidingWindow) retryAfter(counter *windowCounter, start, now time.Time) time.Duration {
+    room := float64(l.maxRequests - 1)
+    if current := float64(counter.current); current <= room && counter.previous > 0 {
+        // previous*(1-x) + current <= room  =>  x >= 1 - (room-current)/previous
+        fraction := 1 - (room-current)/float64(counter.previous)
+        return start.Add(time.Duration(fraction * float64(l.window))).Sub(now)
+    }
+    fraction := 0.0
+    if counter.current > 0 {
+        fraction = max(0, 1-room/float64(counter.current))
+    }
+    return start.Add(l.window + time.Duration(fraction*float64(l.window))).Sub(now)
+}
+
+func (l *SlidingWindow) Stats() Stats {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.counters.snapshot()
+}
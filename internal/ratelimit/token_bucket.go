@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+    "sync"
+    "time"
+)
+
+// TokenBucket holds up to maxRequests tokens per key and refills them evenly
+// over window, so clients may burst up to the limit and are then held to a
+// smooth rate.
+type TokenBucket struct {
+    mu       sync.Mutex
+    capacity float64
+    perSec   float64
+    buckets  *keyStore[*tokens]
+}
+
+type tokens struct {
+    available float64
+    updatedAt time.Time
+}
+
+func NewTokenBucket(config Config) *TokenBucket {
+    return &TokenBucket{
+        capacity: float64(config.MaxRequests),
+        perSec:   float64(config.MaxRequests) / config.Window.Seconds(),
+        // An idle bucket is full again after one window, so forgetting it
+        // then changes nothing.
+        buckets: newKeyStore[*tokens](config.MaxKeys, config.Window),
+    }
+}
+
+func (l *TokenBucket) Allow(key string, now time.Time) Result {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    current := l.buckets.get(key, now, func() *tokens {
+        return &tokens{available: l.capacity, updatedAt: now}
+    })
+
+    if elapsed := now.Sub(current.updatedAt).Seconds(); elapsed > 0 {
+        current.available = min(l.capacity, current.available+elapsed*l.perSec)
+        current.updatedAt = now
+    }
+
+    allowed := current.available >= 1
+    if allowed {
+        current.available--
+    }
+    return bucketResult(allowed, l.capacity, current.available, l.perSec)
+}
+
+// bucketResult describes a token bucket holding available of capacity
+// tokens that refills at perSec.
+func bucketResult(allowed bool, capacity, available, perSec float64) Result {
+    result := Result{
+        Allowed:    allowed,
+        Limit:      int(capacity),
+        Remaining:  int(available),
+        ResetAfter: secondsToDuration((capacity - available) / perSec),
+    }
+    if !allowed {
+        result.RetryAfter = secondsToDuration((1 - available) / perSec)
+    }
+    return result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+    return time.Duration(seconds * float64(time.Second))
+}
+
+func (l *TokenBucket) Stats() Stats {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.buckets.snapshot()
+}
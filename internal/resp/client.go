@@ -0,0 +1,241 @@
+// Package resp is a minimal client for the Redis serialization protocol,
+// covering the handful of commands the engine needs to share state between
+// replicas.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is an error reply sent by the server, as opposed to a network or
+// protocol failure.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+var ErrNil = errors.New("resp: nil reply")
+
+type Options struct {
+	Password    string
+	DialTimeout time.Duration
+	IOTimeout   time.Duration
+	MaxIdle     int
+}
+
+// Client sends commands to one server, reusing up to MaxIdle connections.
+// It is safe for concurrent use.
+type Client struct {
+	addr    string
+	options Options
+	idle    chan *conn
+}
+
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func NewClient(addr string, options Options) *Client {
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = 500 * time.Millisecond
+	}
+	if options.IOTimeout <= 0 {
+		options.IOTimeout = 500 * time.Millisecond
+	}
+	if options.MaxIdle <= 0 {
+		options.MaxIdle = 8
+	}
+	return &Client{addr: addr, options: options, idle: make(chan *conn, options.MaxIdle)}
+}
+
+// Do sends one command and returns its reply: nil, int64, string, Error or
+// []any for arrays. Error replies are returned as the error value.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := cn.roundTrip(ctx, c.options.IOTimeout, args)
+	var replyErr Error
+	if err != nil && !errors.As(err, &replyErr) {
+		_ = cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// EvalScript runs script through EVALSHA, loading it with EVAL when the
+// server does not know it yet.
+func (c *Client) EvalScript(ctx context.Context, script string, keys []string, args ...string) (any, error) {
+	sum := sha1.Sum([]byte(script))
+	command := append([]string{"EVALSHA", hex.EncodeToString(sum[:]), strconv.Itoa(len(keys))}, keys...)
+	reply, err := c.Do(ctx, append(command, args...)...)
+	var replyErr Error
+	if errors.As(err, &replyErr) && strings.HasPrefix(string(replyErr), "NOSCRIPT") {
+		command[0], command[1] = "EVAL", script
+		return c.Do(ctx, append(command, args...)...)
+	}
+	return reply, err
+}
+
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.idle:
+			_ = cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: c.options.DialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: netConn, reader: bufio.NewReader(netConn)}
+	if c.options.Password != "" {
+		if _, err := cn.roundTrip(ctx, c.options.IOTimeout, []string{"AUTH", c.options.Password}); err != nil {
+			_ = cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		_ = cn.Close()
+	}
+}
+
+func (cn *conn) roundTrip(ctx context.Context, timeout time.Duration, args []string) (any, error) {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if _, err := cn.Write(EncodeCommand(args)); err != nil {
+		return nil, err
+	}
+	return ReadReply(cn.reader)
+}
+
+// EncodeCommand encodes args as an array of bulk strings.
+func EncodeCommand(args []string) []byte {
+	var builder strings.Builder
+	builder.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		builder.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	return []byte(builder.String())
+}
+
+// ReadReply decodes one reply. Error replies are returned as an Error.
+func ReadReply(reader *bufio.Reader) (any, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("resp: malformed line %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]any, 0, count)
+		for i := 0; i < count; i++ {
+			item, err := ReadReply(reader)
+			var replyErr Error
+			if err != nil && !errors.As(err, &replyErr) {
+				return nil, err
+			}
+			if err != nil {
+				item = replyErr
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("resp: unknown reply type %q", kind)
+}
+
+// String converts a bulk or simple string reply.
+func String(reply any, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	switch value := reply.(type) {
+	case nil:
+		return "", ErrNil
+	case string:
+		return value, nil
+	}
+	return "", fmt.Errorf("resp: unexpected reply %T", reply)
+}
+
+// Int64 converts an integer reply.
+func Int64(reply any, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch value := reply.(type) {
+	case nil:
+		return 0, ErrNil
+	case int64:
+		return value, nil
+	case string:
+		return strconv.ParseInt(value, 10, 64)
+	}
+	return 0, fmt.Errorf("resp: unexpected reply %T", reply)
+}
@@ -0,0 +1,208 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer answers each command with reply and records the commands it
+// received and the connections it accepted.
+type fakeServer struct {
+	listener net.Listener
+	reply    func(args []string) string
+
+	mu       sync.Mutex
+	commands [][]string
+	accepted int
+}
+
+func newFakeServer(t *testing.T, reply func(args []string) string) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeServer{listener: listener, reply: reply}
+	t.Cleanup(func() { _ = listener.Close() })
+	go server.serve()
+	return server
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.accepted++
+		s.mu.Unlock()
+		go func() {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				request, err := ReadReply(reader)
+				if err != nil {
+					return
+				}
+				var args []string
+				for _, item := range request.([]any) {
+					args = append(args, item.(string))
+				}
+				s.mu.Lock()
+				s.commands = append(s.commands, args)
+				s.mu.Unlock()
+				if _, err := conn.Write([]byte(s.reply(args))); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func (s *fakeServer) received() ([][]string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string(nil), s.commands...), s.accepted
+}
+
+func TestReadReplyDecodesEveryType(t *testing.T) {
+	cases := []struct {
+		wire string
+		want any
+		err  error
+	}{
+		{"+OK\r\n", "OK", nil},
+		{"-ERR boom\r\n", nil, Error("ERR boom")},
+		{":42\r\n", int64(42), nil},
+		{"$5\r\nhello\r\n", "hello", nil},
+		{"$0\r\n\r\n", "", nil},
+		{"$-1\r\n", nil, nil},
+		{"*-1\r\n", nil, nil},
+		{"*3\r\n:1\r\n$1\r\na\r\n-ERR inner\r\n", []any{int64(1), "a", Error("ERR inner")}, nil},
+		{"*2\r\n*1\r\n+x\r\n$-1\r\n", []any{[]any{"x"}, nil}, nil},
+	}
+	for _, tc := range cases {
+		got, err := ReadReply(bufio.NewReader(strings.NewReader(tc.wire)))
+		if !reflect.DeepEqual(got, tc.want) || !reflect.DeepEqual(err, tc.err) {
+			t.Errorf("%q: got %#v, %v; want %#v, %v", tc.wire, got, err, tc.want, tc.err)
+		}
+	}
+
+	for _, wire := range []string{"OK\r\n", "+OK\n", "?1\r\n", ":x\r\n", "$5\r\nab\r\n"} {
+		if _, err := ReadReply(bufio.NewReader(strings.NewReader(wire))); err == nil {
+			t.Errorf("%q: expected an error", wire)
+		}
+	}
+}
+
+func TestEncodeCommandRoundTrips(t *testing.T) {
+	args := []string{"SET", "key", "value with spaces\r\n", ""}
+	got, err := ReadReply(bufio.NewReader(strings.NewReader(string(EncodeCommand(args)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{"SET", "key", "value with spaces\r\n", ""}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestClientAuthenticatesAndReusesConnections(t *testing.T) {
+	server := newFakeServer(t, func(args []string) string {
+		if args[0] == "GET" {
+			return "-ERR no such key\r\n"
+		}
+		return "+OK\r\n"
+	})
+	client := NewClient(server.listener.Addr().String(), Options{Password: "secret"})
+	defer client.Close()
+
+	ctx := context.Background()
+	if reply, err := String(client.Do(ctx, "PING")); err != nil || reply != "OK" {
+		t.Fatalf("PING: %q, %v", reply, err)
+	}
+	// An error reply leaves the connection usable.
+	var replyErr Error
+	if _, err := client.Do(ctx, "GET", "k"); !errors.As(err, &replyErr) {
+		t.Fatalf("expected an Error reply, got %v", err)
+	}
+	if _, err := client.Do(ctx, "PING"); err != nil {
+		t.Fatal(err)
+	}
+
+	commands, accepted := server.received()
+	if accepted != 1 {
+		t.Fatalf("expected one reused connection, got %d", accepted)
+	}
+	if want := [][]string{{"AUTH", "secret"}, {"PING"}, {"GET", "k"}, {"PING"}}; !reflect.DeepEqual(commands, want) {
+		t.Fatalf("got commands %v, want %v", commands, want)
+	}
+}
+
+func TestEvalScriptLoadsUnknownScripts(t *testing.T) {
+	server := newFakeServer(t, func(args []string) string {
+		if args[0] == "EVALSHA" {
+			return "-NOSCRIPT No matching script\r\n"
+		}
+		return ":7\r\n"
+	})
+	client := NewClient(server.listener.Addr().String(), Options{})
+	defer client.Close()
+
+	reply, err := Int64(client.EvalScript(context.Background(), "return 7", []string{"k"}, "a"))
+	if err != nil || reply != 7 {
+		t.Fatalf("got %d, %v", reply, err)
+	}
+	commands, _ := server.received()
+	if len(commands) != 2 || commands[0][0] != "EVALSHA" || !reflect.DeepEqual(commands[1], []string{"EVAL", "return 7", "1", "k", "a"}) {
+		t.Fatalf("expected EVALSHA then EVAL, got %v", commands)
+	}
+}
+
+func TestClientDropsConnectionsThatTimeOut(t *testing.T) {
+	server := newFakeServer(t, func(args []string) string {
+		if args[0] == "SLOW" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return "+OK\r\n"
+	})
+	client := NewClient(server.listener.Addr().String(), Options{IOTimeout: 50 * time.Millisecond})
+	defer client.Close()
+
+	if _, err := client.Do(context.Background(), "SLOW"); err == nil {
+		t.Fatal("expected a timeout")
+	}
+	// The late reply must not be read as the answer to the next command.
+	if reply, err := String(client.Do(context.Background(), "PING")); err != nil || reply != "OK" {
+		t.Fatalf("PING: %q, %v", reply, err)
+	}
+	if _, accepted := server.received(); accepted != 2 {
+		t.Fatalf("expected the timed out connection to be replaced, got %d connections", accepted)
+	}
+}
+
+func TestReplyConversions(t *testing.T) {
+	if _, err := String(nil, nil); !errors.Is(err, ErrNil) {
+		t.Fatalf("expected ErrNil, got %v", err)
+	}
+	if _, err := String(int64(1), nil); err == nil {
+		t.Fatal("expected an integer not to convert to a string")
+	}
+	if value, err := Int64("12", nil); err != nil || value != 12 {
+		t.Fatalf("got %d, %v", value, err)
+	}
+	if _, err := Int64(nil, nil); !errors.Is(err, ErrNil) {
+		t.Fatalf("expected ErrNil, got %v", err)
+	}
+	boom := errors.New("boom")
+	if _, err := Int64(int64(1), boom); err != boom {
+		t.Fatalf("expected the error to pass through, got %v", err)
+	}
+}
@@ -0,0 +1,420 @@
+// Package resptest provides an in-process RESP server for tests. It keeps
+// string and hash keys with optional expiry and lets tests emulate Lua
+// scripts with Go functions.
+package resptest
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/resp"
+)
+
+// Status is written as a simple string reply such as +OK.
+type Status string
+
+// ScriptFunc emulates a Lua script. It runs under the server lock, so it may
+// use the unlocked accessors on the server it receives.
+type ScriptFunc func(s *Server, keys, args []string) any
+
+type Server struct {
+	listener net.Listener
+	mu       sync.Mutex
+	data     map[string]item
+	scripts  map[string]ScriptFunc
+	now      func() time.Time
+	closed   bool
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// item is a string key, or a hash key when hash is not nil.
+type item struct {
+	value     string
+	hash      map[string]string
+	expiresAt time.Time
+}
+
+// NewServer starts a server on a random loopback port.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	server := &Server{
+		listener: listener,
+		data:     make(map[string]item),
+		scripts:  make(map[string]ScriptFunc),
+		now:      time.Now,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	server.wg.Add(1)
+	go server.serve()
+	return server, nil
+}
+
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// SetClock overrides the clock used for key expiry and TIME.
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// Now returns the server's clock, which scripts read with TIME. Like the
+// other accessors it is unlocked, for use from a ScriptFunc.
+func (s *Server) Now() time.Time {
+	return s.now()
+}
+
+// RegisterScript makes EVAL and EVALSHA of script run fn.
+func (s *Server) RegisterScript(script string, fn ScriptFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[sha(script)] = fn
+}
+
+// Close stops accepting connections and drops open ones, which clients see
+// as the server becoming unreachable.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	_ = s.listener.Close()
+	s.wg.Wait()
+}
+
+// Get returns the value of key. Callers outside a ScriptFunc must not hold
+// the server lock; the accessors are unlocked by design for script use.
+func (s *Server) Get(key string) (string, bool) {
+	current, ok := s.data[key]
+	if !ok {
+		return "", false
+	}
+	if !current.expiresAt.IsZero() && !s.now().Before(current.expiresAt) {
+		delete(s.data, key)
+		return "", false
+	}
+	return current.value, true
+}
+
+// Set stores value under key. A zero ttl keeps the key forever.
+func (s *Server) Set(key, value string, ttl time.Duration) {
+	current := item{value: value}
+	if ttl > 0 {
+		current.expiresAt = s.now().Add(ttl)
+	}
+	s.data[key] = current
+}
+
+// HashGet returns field of the hash at key.
+func (s *Server) HashGet(key, field string) (string, bool) {
+	if _, ok := s.Get(key); !ok {
+		return "", false
+	}
+	value, ok := s.data[key].hash[field]
+	return value, ok
+}
+
+// HashSet sets field of the hash at key, creating it without expiry.
+func (s *Server) HashSet(key, field, value string) {
+	if _, ok := s.Get(key); !ok || s.data[key].hash == nil {
+		s.data[key] = item{hash: make(map[string]string)}
+	}
+	current := s.data[key]
+	current.hash[field] = value
+	s.data[key] = current
+}
+
+// Expire sets the time to live of key and reports whether it exists.
+func (s *Server) Expire(key string, ttl time.Duration) bool {
+	if _, ok := s.Get(key); !ok {
+		return false
+	}
+	current := s.data[key]
+	current.expiresAt = s.now().Add(ttl)
+	s.data[key] = current
+	return true
+}
+
+// Delete removes key and reports whether it existed.
+func (s *Server) Delete(key string) bool {
+	if _, ok := s.Get(key); !ok {
+		return false
+	}
+	delete(s.data, key)
+	return true
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		request, err := resp.ReadReply(reader)
+		if err != nil {
+			return
+		}
+		items, ok := request.([]any)
+		if !ok || len(items) == 0 {
+			return
+		}
+		args := make([]string, 0, len(items))
+		for _, value := range items {
+			text, _ := value.(string)
+			args = append(args, text)
+		}
+
+		s.mu.Lock()
+		reply := s.execute(args)
+		s.mu.Unlock()
+
+		if _, err := conn.Write(encode(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) execute(args []string) any {
+	switch command := strings.ToUpper(args[0]); command {
+	case "PING":
+		return Status("PONG")
+	case "AUTH":
+		return Status("OK")
+	case "TIME":
+		now := s.now()
+		return []any{strconv.FormatInt(now.Unix(), 10), strconv.Itoa(now.Nanosecond() / 1000)}
+	case "GET":
+		if len(args) != 2 {
+			return arityError(command)
+		}
+		value, ok := s.Get(args[1])
+		if !ok {
+			return nil
+		}
+		if s.data[args[1]].hash != nil {
+			return wrongType()
+		}
+		return value
+	case "SET":
+		if len(args) != 3 && len(args) != 5 {
+			return arityError(command)
+		}
+		var ttl time.Duration
+		if len(args) == 5 {
+			ms, err := strconv.ParseInt(args[4], 10, 64)
+			if err != nil || strings.ToUpper(args[3]) != "PX" {
+				return resp.Error("ERR syntax error")
+			}
+			ttl = time.Duration(ms) * time.Millisecond
+		}
+		s.Set(args[1], args[2], ttl)
+		return Status("OK")
+	case "HSET":
+		if len(args) < 4 || len(args)%2 != 0 {
+			return arityError(command)
+		}
+		if _, ok := s.Get(args[1]); ok && s.data[args[1]].hash == nil {
+			return wrongType()
+		}
+		var added int64
+		for i := 2; i < len(args); i += 2 {
+			if _, ok := s.HashGet(args[1], args[i]); !ok {
+				added++
+			}
+			s.HashSet(args[1], args[i], args[i+1])
+		}
+		return added
+	case "HMGET":
+		if len(args) < 3 {
+			return arityError(command)
+		}
+		if _, ok := s.Get(args[1]); ok && s.data[args[1]].hash == nil {
+			return wrongType()
+		}
+		values := make([]any, 0, len(args)-2)
+		for _, field := range args[2:] {
+			if value, ok := s.HashGet(args[1], field); ok {
+				values = append(values, value)
+			} else {
+				values = append(values, nil)
+			}
+		}
+		return values
+	case "PEXPIRE":
+		if len(args) != 3 {
+			return arityError(command)
+		}
+		ms, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return resp.Error("ERR value is not an integer or out of range")
+		}
+		if s.Expire(args[1], time.Duration(ms)*time.Millisecond) {
+			return int64(1)
+		}
+		return int64(0)
+	case "PTTL":
+		if len(args) != 2 {
+			return arityError(command)
+		}
+		if _, ok := s.Get(args[1]); !ok {
+			return int64(-2)
+		}
+		if expiresAt := s.data[args[1]].expiresAt; !expiresAt.IsZero() {
+			return expiresAt.Sub(s.now()).Milliseconds()
+		}
+		return int64(-1)
+	case "DEL":
+		var removed int64
+		for _, key := range args[1:] {
+			if s.Delete(key) {
+				removed++
+			}
+		}
+		return removed
+	case "SCAN":
+		return s.scan(args)
+	case "EVAL", "EVALSHA":
+		if len(args) < 3 {
+			return arityError(command)
+		}
+		digest := args[1]
+		if command == "EVAL" {
+			digest = sha(args[1])
+		}
+		script, ok := s.scripts[digest]
+		if !ok {
+			return resp.Error("NOSCRIPT No matching script")
+		}
+		count, err := strconv.Atoi(args[2])
+		if err != nil || count < 0 || 3+count > len(args) {
+			return resp.Error("ERR invalid number of keys")
+		}
+		return script(s, args[3:3+count], args[3+count:])
+	}
+	return resp.Error("ERR unknown command '" + args[0] + "'")
+}
+
+// scan serves SCAN cursor [MATCH pattern] [COUNT count]. The cursor is an
+// offset into the sorted live keys, so keys added or removed between calls
+// may be skipped or repeated, as Redis allows.
+func (s *Server) scan(args []string) any {
+	if len(args) < 2 || len(args)%2 != 0 {
+		return arityError(args[0])
+	}
+	cursor, err := strconv.Atoi(args[1])
+	if err != nil || cursor < 0 {
+		return resp.Error("ERR invalid cursor")
+	}
+	pattern, count := "*", 10
+	for i := 2; i < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 1 {
+				return resp.Error("ERR value is not an integer or out of range")
+			}
+		default:
+			return resp.Error("ERR syntax error")
+		}
+	}
+
+	keys := make([]string, 0, len(s.data))
+	for key := range s.data {
+		if _, ok := s.Get(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	end := cursor + count
+	next := strconv.Itoa(end)
+	if end >= len(keys) {
+		end, next = len(keys), "0"
+	}
+	matches := []any{}
+	for _, key := range keys[min(cursor, end):end] {
+		if matched, _ := path.Match(pattern, key); matched {
+			matches = append(matches, key)
+		}
+	}
+	return []any{next, matches}
+}
+
+func wrongType() resp.Error {
+	return resp.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+}
+
+func arityError(command string) resp.Error {
+	return resp.Error("ERR wrong number of arguments for '" + strings.ToLower(command) + "' command")
+}
+
+func encode(reply any) []byte {
+	switch value := reply.(type) {
+	case nil:
+		return []byte("$-1\r\n")
+	case Status:
+		return []byte("+" + string(value) + "\r\n")
+	case resp.Error:
+		return []byte("-" + string(value) + "\r\n")
+	case int64:
+		return []byte(":" + strconv.FormatInt(value, 10) + "\r\n")
+	case int:
+		return []byte(":" + strconv.Itoa(value) + "\r\n")
+	case string:
+		return []byte("$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n")
+	case []any:
+		out := []byte("*" + strconv.Itoa(len(value)) + "\r\n")
+		for _, element := range value {
+			out = append(out, encode(element)...)
+		}
+		return out
+	}
+	return []byte("-ERR unsupported reply\r\n")
+}
+
+func sha(script string) string {
+	sum := sha1.Sum([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
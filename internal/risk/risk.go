@@ -0,0 +1,185 @@
+// Package risk runs pre-trade checks that reject orders before they are
+// routed: quantity and notional caps, globally and per symbol, restricted
+// symbols and fat-finger price bands.
+package risk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Codes identifying which check rejected an order.
+const (
+	CodeRestrictedSymbol = "RESTRICTED_SYMBOL"
+	CodeMaxQuantity      = "MAX_QUANTITY_EXCEEDED"
+	CodeMaxNotional      = "MAX_NOTIONAL_EXCEEDED"
+	CodePriceRequired    = "PRICE_REQUIRED"
+	CodeNoReferencePrice = "REFERENCE_PRICE_REQUIRED"
+	CodePriceOutOfBand   = "PRICE_OUTSIDE_BAND"
+)
+
+var ErrInvalidLimits = errors.New("invalid risk limits")
+
+// Order is what the checks need to know about an order. A zero Price means
+// a market order.
+type Order struct {
+	Symbol   string
+	Quantity int64
+	Price    float64
+}
+
+// Violation is the error Check returns for a rejected order. Limit and Actual
+// are the breached limit and the order's value it was compared against.
+type Violation struct {
+	Code    string  `json:"code"`
+	Message string  `json:"message"`
+	Limit   float64 `json:"limit,omitempty"`
+	Actual  float64 `json:"actual,omitempty"`
+}
+
+func (v *Violation) Error() string {
+	return v.Message
+}
+
+// SymbolLimits override the global limits for one symbol. Zero fields fall
+// back to the global value. ReferencePrice anchors the symbol's price band
+// and prices market orders for the notional check.
+type SymbolLimits struct {
+	MaxQuantity      int64   `json:"maxQuantity"`
+	MaxNotional      float64 `json:"maxNotional"`
+	ReferencePrice   float64 `json:"referencePrice"`
+	PriceBandPercent float64 `json:"priceBandPercent"`
+}
+
+// Limits are the pre-trade checks applied to every order. Zero limits are
+// not enforced. A nil *Limits accepts every order.
+type Limits struct {
+	MaxQuantity       int64                   `json:"maxQuantity"`
+	MaxNotional       float64                 `json:"maxNotional"`
+	PriceBandPercent  float64                 `json:"priceBandPercent"`
+	RestrictedSymbols []string                `json:"restrictedSymbols"`
+	Symbols           map[string]SymbolLimits `json:"symbols"`
+
+	restricted map[string]bool
+}
+
+// NewLimits validates limits and indexes them by upper-cased symbol.
+func NewLimits(limits Limits) (*Limits, error) {
+	if limits.MaxQuantity < 0 || limits.MaxNotional < 0 || limits.PriceBandPercent < 0 {
+		return nil, fmt.Errorf("%w: limits must not be negative", ErrInvalidLimits)
+	}
+	limits.restricted = make(map[string]bool, len(limits.RestrictedSymbols))
+	for _, symbol := range limits.RestrictedSymbols {
+		limits.restricted[normalize(symbol)] = true
+	}
+	symbols := make(map[string]SymbolLimits, len(limits.Symbols))
+	for symbol, symbolLimits := range limits.Symbols {
+		if symbolLimits.MaxQuantity < 0 || symbolLimits.MaxNotional < 0 || symbolLimits.ReferencePrice < 0 || symbolLimits.PriceBandPercent < 0 {
+			return nil, fmt.Errorf("%w: limits of %s must not be negative", ErrInvalidLimits, symbol)
+		}
+		symbols[normalize(symbol)] = symbolLimits
+	}
+	limits.Symbols = symbols
+	return &limits, nil
+}
+
+// LoadLimits reads limits from a JSON file.
+func LoadLimits(path string) (*Limits, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var limits Limits
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLimits, err)
+	}
+	return NewLimits(limits)
+}
+
+// Check returns a *Violation for the first check order fails, or nil.
+// Restricted symbols are checked first, then quantity, notional and price
+// band. The notional of a market order is priced at the symbol's reference
+// price; without one, a notional limit cannot be enforced and the order is
+// rejected. Likewise a priced order for a symbol with no reference price is
+// rejected when a price band, global or its own, applies to it.
+func (l *Limits) Check(order Order) error {
+	if l == nil {
+		return nil
+	}
+	symbol := normalize(order.Symbol)
+	if l.restricted[symbol] {
+		return &Violation{Code: CodeRestrictedSymbol, Message: "symbol " + symbol + " is restricted"}
+	}
+
+	symbolLimits := l.Symbols[symbol]
+	maxQuantity := pick(float64(symbolLimits.MaxQuantity), float64(l.MaxQuantity))
+	if maxQuantity > 0 && float64(order.Quantity) > maxQuantity {
+		return &Violation{
+			Code:    CodeMaxQuantity,
+			Message: fmt.Sprintf("quantity %d exceeds the limit of %s for %s", order.Quantity, format(maxQuantity), symbol),
+			Limit:   maxQuantity,
+			Actual:  float64(order.Quantity),
+		}
+	}
+
+	maxNotional := pick(symbolLimits.MaxNotional, l.MaxNotional)
+	if maxNotional > 0 {
+		price := order.Price
+		if price == 0 {
+			price = symbolLimits.ReferencePrice
+		}
+		if price == 0 {
+			return &Violation{Code: CodePriceRequired, Message: "a price is required to check the notional limit for " + symbol}
+		}
+		notional := price * float64(order.Quantity)
+		if notional > maxNotional {
+			return &Violation{
+				Code:    CodeMaxNotional,
+				Message: fmt.Sprintf("notional %s exceeds the limit of %s for %s", format(notional), format(maxNotional), symbol),
+				Limit:   maxNotional,
+				Actual:  notional,
+			}
+		}
+	}
+
+	band := pick(symbolLimits.PriceBandPercent, l.PriceBandPercent)
+	if band > 0 && order.Price > 0 {
+		reference := symbolLimits.ReferencePrice
+		if reference == 0 {
+			return &Violation{Code: CodeNoReferencePrice, Message: "a reference price is required to check the price band for " + symbol}
+		}
+		deviation := (order.Price - reference) / reference * 100
+		if deviation < 0 {
+			deviation = -deviation
+		}
+		if deviation > band {
+			return &Violation{
+				Code:    CodePriceOutOfBand,
+				Message: fmt.Sprintf("price %s is %s%% away from the reference price %s of %s, beyond the %s%% band", format(order.Price), strconv.FormatFloat(deviation, 'f', 2, 64), format(reference), symbol, format(band)),
+				Limit:   band,
+				Actual:  deviation,
+			}
+		}
+	}
+	return nil
+}
+
+func normalize(symbol string) string {
+	return strings.ToUpper(strings.TrimSpace(symbol))
+}
+
+// pick returns the symbol's value when set, and the global one otherwise.
+func pick(symbolValue, globalValue float64) float64 {
+	if symbolValue > 0 {
+		return symbolValue
+	}
+	return globalValue
+}
+
+func format(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,55 @@
+package risk
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckAppliesSymbolLimitsOverGlobalOnes(t *testing.T) {
+	limits, err := NewLimits(Limits{
+		MaxQuantity:       1000,
+		MaxNotional:       50000,
+		PriceBandPercent:  10,
+		RestrictedSymbols: []string{"xyz"},
+		Symbols: map[string]SymbolLimits{
+			"aapl": {MaxQuantity: 100, ReferencePrice: 200},
+			"brk":  {MaxNotional: 1e6, ReferencePrice: 600000, PriceBandPercent: 5},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		order Order
+		code  string
+	}{
+		{"accepted", Order{Symbol: "AAPL", Quantity: 100, Price: 210}, ""},
+		{"restricted", Order{Symbol: "XYZ ", Quantity: 1, Price: 1}, CodeRestrictedSymbol},
+		{"symbol quantity", Order{Symbol: "AAPL", Quantity: 101, Price: 200}, CodeMaxQuantity},
+		{"global quantity", Order{Symbol: "MSFT", Quantity: 1001, Price: 1}, CodeMaxQuantity},
+		{"global notional", Order{Symbol: "MSFT", Quantity: 600, Price: 100}, CodeMaxNotional},
+		{"market order priced at reference", Order{Symbol: "AAPL", Quantity: 100}, ""},
+		{"market order without reference", Order{Symbol: "MSFT", Quantity: 10}, CodePriceRequired},
+		{"symbol notional", Order{Symbol: "BRK", Quantity: 2}, CodeMaxNotional},
+		{"global band", Order{Symbol: "AAPL", Quantity: 10, Price: 221}, CodePriceOutOfBand},
+		{"global band without reference", Order{Symbol: "MSFT", Quantity: 10, Price: 100}, CodeNoReferencePrice},
+		{"symbol band", Order{Symbol: "BRK", Quantity: 1, Price: 631000}, CodePriceOutOfBand},
+	}
+	for _, tc := range cases {
+		err := limits.Check(tc.order)
+		var violation *Violation
+		switch {
+		case tc.code == "" && err != nil:
+			t.Errorf("%s: unexpected rejection %v", tc.name, err)
+		case tc.code != "" && (!errors.As(err, &violation) || violation.Code != tc.code):
+			t.Errorf("%s: expected %s, got %v", tc.name, tc.code, err)
+		}
+	}
+
+	var none *Limits
+	if err := none.Check(Order{Symbol: "XYZ", Quantity: 1e9}); err != nil {
+		t.Fatalf("nil limits should accept every order, got %v", err)
+	}
+}
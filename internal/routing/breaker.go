@@ -0,0 +1,190 @@
+package routing
+
+import (
+    "sync"
+    "time"
+)
+
+type BreakerState string
+
+const (
+    BreakerClosed   BreakerState = "closed"
+    BreakerOpen     BreakerState = "open"
+    BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerConfig controls when a target's circuit trips and how long it stays
+// open before a single trial order is let through.
+type BreakerConfig struct {
+    FailureThreshold int
+    OpenFor          time.Duration
+}
+
+func DefaultBreakerConfig() BreakerConfig {
+    return BreakerConfig{
+        FailureThreshold: 5,
+        OpenFor:          30 * time.Second,
+    }
+}
+
+// Breakers keeps one circuit breaker per target ID. A circuit opens after
+// FailureThreshold consecutive failures. Once OpenFor has elapsed it turns
+// half-open and admits one trial order; the outcome of that order closes the
+// circuit again or re-opens it.
+type Breakers struct {
+    mu       sync.Mutex
+    config   BreakerConfig
+    circuits map[string]*circuit
+}
+
+type circuit struct {
+    state    BreakerState
+    failures int
+    openedAt time.Time
+    trialAt  time.Time
+}
+
+func NewBreakers(config BreakerConfig) *Breakers {
+    if config.FailureThreshold <= 0 {
+        config.FailureThreshold = DefaultBreakerConfig().FailureThreshold
+    }
+    if config.OpenFor <= 0 {
+        config.OpenFor = DefaultBreakerConfig().OpenFor
+    }
+    return &Breakers{
+        config:   config,
+        circuits: make(map[string]*circuit),
+    }
+}
+
+// Record feeds the outcome of an order sent to targetID into its circuit and
+// returns the resulting state.
+func (b *Breakers) Record(targetID string, success bool, now time.Time) BreakerState {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    current := b.circuit(targetID)
+    b.advance(current, now)
+    if success {
+        current.state = BreakerClosed
+        current.failures = 0
+        return current.state
+    }
+
+    current.failures++
+    if current.state == BreakerHalfOpen || current.failures >= b.config.FailureThreshold {
+        current.state = BreakerOpen
+        current.openedAt = now
+        current.trialAt = time.Time{}
+    }
+    return current.state
+}
+
+func (b *Breakers) State(targetID string, now time.Time) BreakerState {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    current, ok := b.circuits[targetID]
+    if !ok {
+        return BreakerClosed
+    }
+    b.advance(current, now)
+    return current.state
+}
+
+// partition splits targets into those whose circuit admits an order and
+// those skipped because it is open. A nil Breakers admits every target.
+func (b *Breakers) partition(targets []Target, now time.Time) ([]Target, []Target) {
+    if b == nil {
+        return targets, nil
+    }
+    allowed := make([]Target, 0, len(targets))
+    var tripped []Target
+    for _, target := range targets {
+        if b.available(target.ID, now) {
+            allowed = append(allowed, target)
+        } else {
+            tripped = append(tripped, target)
+        }
+    }
+    return allowed, tripped
+}
+
+// available reports whether targetID may receive an order without changing
+// any state.
+func (b *Breakers) available(targetID string, now time.Time) bool {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    current, ok := b.circuits[targetID]
+    if !ok {
+        return true
+    }
+    b.advance(current, now)
+    switch current.state {
+    case BreakerOpen:
+        return false
+    case BreakerHalfOpen:
+        return current.trialAt.IsZero() || now.Sub(current.trialAt) >= b.config.OpenFor
+    }
+    return true
+}
+
+// tryAcquire reports whether targetID may receive an order and, for a
+// half-open circuit, claims its trial slot in the same locked step so two
+// concurrent decisions cannot both probe a recovering target. A trial that
+// never reports back frees the slot again after OpenFor.
+func (b *Breakers) tryAcquire(targetID string, now time.Time) bool {
+    if b == nil {
+        return true
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    current, ok := b.circuits[targetID]
+    if !ok {
+        return true
+    }
+    b.advance(current, now)
+    switch current.state {
+    case BreakerOpen:
+        return false
+    case BreakerHalfOpen:
+        if !current.trialAt.IsZero() && now.Sub(current.trialAt) < b.config.OpenFor {
+            return false
+        }
+        current.trialAt = now
+    }
+    return true
+}
+
+// release hands back a trial slot claimed by tryAcquire at now when the
+// decision that claimed it routed nothing to targetID after all.
+func (b *Breakers) release(targetID string, now time.Time) {
+    if b == nil {
+        return
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    current, ok := b.circuits[targetID]
+    if ok && current.state == BreakerHalfOpen && current.trialAt.Equal(now) {
+        current.trialAt = time.Time{}
+    }
+}
+
+func (b *Breakers) circuit(targetID string) *circuit {
+    current, ok := b.circuits[targetID]
+    if !ok {
+        current = &circuit{state: BreakerClosed}
+        b.circuits[targetID] = current
+    }
+    return current
+}
+
+func (b *Breakers) advance(current *circuit, now time.Time) {
+    if current.state == BreakerOpen && now.Sub(current.openedAt) >= b.config.OpenFor {
+        current.state = BreakerHalfOpen
+        current.trialAt = time.Time{}
+    }
+}
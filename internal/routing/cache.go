@@ -1,47 +1,391 @@
 package routing
 
 import (
+	"math"
+	"sort"
 	"sync"
 	"time"
 )
 
-type MetricCache struct {
-	mu      sync.RWMutex
-	ttl     time.Duration
-	metrics map[string]metricEntry
+// Latency statistics MetricConfig.Latency can route on.
+const (
+	LatencyEWMA = "ewma"
+	LatencyP50  = "p50"
+	LatencyP99  = "p99"
+)
+
+// MetricConfig controls how the metric cache smooths the latency and
+// availability samples it sees for each target.
+type MetricConfig struct {
+	// TTL is how long a target's metrics survive without a new sample.
+	TTL time.Duration
+	// Decay is the weight, in (0, 1], a new sample gets in the exponentially
+	// weighted moving averages. Higher values react faster and smooth less.
+	Decay float64
+	// Window is how many recent latency samples per target the percentiles
+	// are computed over.
+	Window int
+	// Latency selects the statistic merged targets carry as LatencyMs:
+	// LatencyEWMA, LatencyP50 or LatencyP99.
+	Latency string
+}
+
+func DefaultMetricConfig() MetricConfig {
+	return MetricConfig{
+		TTL:     30 * time.Second,
+		Decay:   0.2,
+		Window:  100,
+		Latency: LatencyEWMA,
+	}
+}
+
+// MetricCache smooths the latency and availability reported for each target
+// across requests, outcome reports and probes. Availability and latency are
+// exponentially weighted moving averages; a rolling window of raw latency
+// samples backs the percentiles. Implementations must be safe for concurrent
+// use.
+type MetricCache interface {
+	// Merge folds the metrics each target claims into the cache as one more
+	// sample and returns the targets carrying the smoothed values instead.
+	Merge(targets []Target, now time.Time) []Target
+	// Apply returns targets carrying the cached metrics of those with fresh
+	// samples or an active override, without folding their own metrics in.
+	// It suits metrics that are a baseline rather than an observation, such
+	// as those of registered venues.
+	Apply(targets []Target, now time.Time) []Target
+	// Observe folds an execution outcome reported for targetID into the
+	// cache. A negative latencyMs means no latency was observed, in which
+	// case only the availability of an already cached target is updated.
+	Observe(targetID string, latencyMs int64, available bool, now time.Time)
+	// Record folds metrics the engine measured itself, such as active health
+	// probes, into the cache as one more sample. A negative latencyMs means
+	// no latency was measured, in which case only availability is folded in.
+	Record(targetID string, latencyMs int64, availability float64, now time.Time)
+	// Pin makes targetID report latencyMs and availability until until.
+	Pin(targetID string, latencyMs int64, availability float64, until time.Time)
+	// MarkDown makes targetID report an availability of 0 until until.
+	MarkDown(targetID string, until time.Time)
+	// Reset forgets everything cached about targetID, overrides included,
+	// and reports whether there was anything to forget.
+	Reset(targetID string) bool
+	// Targets returns the metrics of every target with fresh samples or an
+	// active override, ordered by target ID.
+	Targets(now time.Time) []TargetMetrics
+}
+
+// MemoryMetricCache is the MetricCache of a single replica.
+type MemoryMetricCache struct {
+	mu        sync.RWMutex
+	config    MetricConfig
+	metrics   map[string]*metricEntry
+	overrides map[string]Override
 }
 
 type metricEntry struct {
-	latencyMs    int64
+	latencyEWMA  float64
 	availability float64
+	latencies    latencyWindow
 	updatedAt    time.Time
 }
 
-func NewMetricCache(ttl time.Duration) *MetricCache {
-	return &MetricCache{
-		ttl:     ttl,
-		metrics: make(map[string]metricEntry),
+func NewMemoryMetricCache(config MetricConfig) *MemoryMetricCache {
+	config = config.withDefaults()
+	return &MemoryMetricCache{
+		config:    config,
+		metrics:   make(map[string]*metricEntry),
+		overrides: make(map[string]Override),
 	}
 }
 
-func (c *MetricCache) Merge(targets []Target, now time.Time) []Target {
+func (config MetricConfig) withDefaults() MetricConfig {
+	defaults := DefaultMetricConfig()
+	if config.TTL <= 0 {
+		config.TTL = defaults.TTL
+	}
+	if config.Decay <= 0 || config.Decay > 1 {
+		config.Decay = defaults.Decay
+	}
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	switch config.Latency {
+	case LatencyEWMA, LatencyP50, LatencyP99:
+	default:
+		config.Latency = defaults.Latency
+	}
+	return config
+}
+
+// Override kinds.
+const (
+	OverridePin  = "pin"
+	OverrideDown = "down"
+)
+
+// Override is an operator's correction of what the cache believes about a
+// target. Until it expires, merged targets report its values whatever samples
+// arrive; the samples are still smoothed so nothing is lost when it lapses.
+type Override struct {
+	Kind string `json:"kind"`
+	// LatencyMs and Availability are the pinned values. A down target keeps
+	// its smoothed latency and reports an availability of 0.
+	LatencyMs    int64     `json:"latencyMs,omitempty"`
+	Availability float64   `json:"availability"`
+	Until        time.Time `json:"until"`
+}
+
+// TargetMetrics is what the cache currently believes about one target.
+type TargetMetrics struct {
+	TargetID      string    `json:"targetId"`
+	LatencyMs     int64     `json:"latencyMs"`
+	LatencyEWMAMs int64     `json:"latencyEwmaMs"`
+	LatencyP50Ms  int64     `json:"latencyP50Ms"`
+	LatencyP99Ms  int64     `json:"latencyP99Ms"`
+	Availability  float64   `json:"availability"`
+	Samples       int       `json:"samples"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	AgeMs         int64     `json:"ageMs"`
+	Override      *Override `json:"override,omitempty"`
+}
+
+func (c *MemoryMetricCache) Merge(targets []Target, now time.Time) []Target {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	merged := make([]Target, 0, len(targets))
 	for _, target := range targets {
-		if cached, ok := c.metrics[target.ID]; ok && now.Sub(cached.updatedAt) <= c.ttl {
-			target.LatencyMs = (target.LatencyMs + cached.latencyMs) / 2
-			target.Availability = (target.Availability + cached.availability) / 2
+		entry := c.sample(target.ID, target.LatencyMs, target.Availability, now)
+		var override *Override
+		if active, ok := c.override(target.ID, now); ok {
+			override = &active
 		}
+		merged = append(merged, c.config.apply(target, entry, override))
+	}
+	return merged
+}
+
+func (c *MemoryMetricCache) Apply(targets []Target, now time.Time) []Target {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-		entry := metricEntry{
-			latencyMs:    target.LatencyMs,
-			availability: target.Availability,
-			updatedAt:    now,
+	applied := make([]Target, 0, len(targets))
+	for _, target := range targets {
+		var override *Override
+		if active, ok := c.override(target.ID, now); ok {
+			override = &active
 		}
-		c.metrics[target.ID] = entry
-		merged = append(merged, target)
+		applied = append(applied, c.config.apply(target, c.fresh(target.ID, now), override))
 	}
-	return merged
+	return applied
+}
+
+func (c *MemoryMetricCache) Observe(targetID string, latencyMs int64, available bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sample := 0.0
+	if available {
+		sample = 1
+	}
+
+	if latencyMs < 0 && c.fresh(targetID, now) == nil {
+		return
+	}
+	c.sample(targetID, latencyMs, sample, now)
+}
+
+func (c *MemoryMetricCache) Record(targetID string, latencyMs int64, availability float64, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sample(targetID, latencyMs, availability, now)
+}
+
+func (c *MemoryMetricCache) Pin(targetID string, latencyMs int64, availability float64, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.overrides[targetID] = Override{Kind: OverridePin, LatencyMs: latencyMs, Availability: availability, Until: until}
+}
+
+func (c *MemoryMetricCache) MarkDown(targetID string, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.overrides[targetID] = Override{Kind: OverrideDown, Until: until}
+}
+
+func (c *MemoryMetricCache) Reset(targetID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, cached := c.metrics[targetID]
+	_, overridden := c.overrides[targetID]
+	delete(c.metrics, targetID)
+	delete(c.overrides, targetID)
+	return cached || overridden
+}
+
+func (c *MemoryMetricCache) Targets(now time.Time) []TargetMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	byID := make(map[string]*TargetMetrics)
+	for id, entry := range c.metrics {
+		if now.Sub(entry.updatedAt) > c.config.TTL {
+			delete(c.metrics, id)
+			continue
+		}
+		byID[id] = &TargetMetrics{
+			TargetID:      id,
+			LatencyMs:     entry.latency(c.config.Latency),
+			LatencyEWMAMs: entry.latency(LatencyEWMA),
+			LatencyP50Ms:  entry.latency(LatencyP50),
+			LatencyP99Ms:  entry.latency(LatencyP99),
+			Availability:  entry.availability,
+			Samples:       len(entry.latencies.samples),
+			UpdatedAt:     entry.updatedAt,
+			AgeMs:         now.Sub(entry.updatedAt).Milliseconds(),
+		}
+	}
+	for id := range c.overrides {
+		override, ok := c.override(id, now)
+		if !ok {
+			continue
+		}
+		metrics, ok := byID[id]
+		if !ok {
+			metrics = &TargetMetrics{TargetID: id}
+			byID[id] = metrics
+		}
+		metrics.LatencyMs, metrics.Availability = override.apply(metrics.LatencyMs)
+		metrics.Override = &override
+	}
+
+	targets := make([]TargetMetrics, 0, len(byID))
+	for _, metrics := range byID {
+		targets = append(targets, *metrics)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].TargetID < targets[j].TargetID })
+	return targets
+}
+
+// override returns the active override of targetID, dropping it once it
+// has expired.
+func (c *MemoryMetricCache) override(targetID string, now time.Time) (Override, bool) {
+	override, ok := c.overrides[targetID]
+	if !ok {
+		return Override{}, false
+	}
+	if !now.Before(override.Until) {
+		delete(c.overrides, targetID)
+		return Override{}, false
+	}
+	return override, true
+}
+
+// apply overlays what is cached about target, entry and override, either of
+// which may be nil. An entry without latency samples keeps target's latency.
+func (config MetricConfig) apply(target Target, entry *metricEntry, override *Override) Target {
+	if entry != nil {
+		if len(entry.latencies.samples) > 0 {
+			target.LatencyMs = entry.latency(config.Latency)
+		}
+		target.Availability = entry.availability
+	}
+	if override != nil {
+		target.LatencyMs, target.Availability = override.apply(target.LatencyMs)
+	}
+	return target
+}
+
+func (o Override) apply(latencyMs int64) (int64, float64) {
+	if o.Kind == OverrideDown {
+		return latencyMs, 0
+	}
+	return o.LatencyMs, o.Availability
+}
+
+// fresh returns the entry of targetID, or nil if there is none within TTL.
+func (c *MemoryMetricCache) fresh(targetID string, now time.Time) *metricEntry {
+	entry, ok := c.metrics[targetID]
+	if !ok || now.Sub(entry.updatedAt) > c.config.TTL {
+		return nil
+	}
+	return entry
+}
+
+// sample folds one sample into the entry of targetID, starting a new entry
+// when there is none within TTL. A negative latencyMs leaves latency alone.
+func (c *MemoryMetricCache) sample(targetID string, latencyMs int64, availability float64, now time.Time) *metricEntry {
+	entry := c.fresh(targetID, now)
+	if entry == nil {
+		entry = &metricEntry{availability: availability}
+		c.metrics[targetID] = entry
+	} else {
+		entry.availability = ewma(entry.availability, availability, c.config.Decay)
+	}
+	if latencyMs >= 0 {
+		entry.observeLatency(latencyMs, c.config)
+	}
+	entry.updatedAt = now
+	return entry
+}
+
+// observeLatency adds a latency sample; the first one seeds the average.
+func (e *metricEntry) observeLatency(latencyMs int64, config MetricConfig) {
+	if len(e.latencies.samples) == 0 {
+		e.latencyEWMA = float64(latencyMs)
+	} else {
+		e.latencyEWMA = ewma(e.latencyEWMA, float64(latencyMs), config.Decay)
+	}
+	e.latencies.add(latencyMs, config.Window)
+}
+
+func (e *metricEntry) latency(stat string) int64 {
+	switch stat {
+	case LatencyP50:
+		return e.latencies.percentile(50)
+	case LatencyP99:
+		return e.latencies.percentile(99)
+	}
+	return int64(math.Round(e.latencyEWMA))
+}
+
+func ewma(current, sample, decay float64) float64 {
+	return decay*sample + (1-decay)*current
+}
+
+// latencyWindow is a ring buffer of a target's most recent latency samples.
+type latencyWindow struct {
+	samples []int64
+	next    int
+}
+
+func (w *latencyWindow) add(latencyMs int64, size int) {
+	if len(w.samples) < size {
+		w.samples = append(w.samples, latencyMs)
+		return
+	}
+	w.samples[w.next] = latencyMs
+	w.next = (w.next + 1) % size
+}
+
+// ordered returns the samples oldest first.
+func (w *latencyWindow) ordered() []int64 {
+	return append(append([]int64(nil), w.samples[w.next:]...), w.samples[:w.next]...)
+}
+
+// percentile returns the nearest-rank percentile p of the window.
+func (w *latencyWindow) percentile(p int) int64 {
+	if len(w.samples) == 0 {
+		return 0
+	}
+	sorted := append([]int64(nil), w.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
 }
@@ -0,0 +1,154 @@
+package routing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricCacheDampensOutliers(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := NewMemoryMetricCache(MetricConfig{Decay: 0.2, Window: 10})
+	for i := 0; i < 9; i++ {
+		cache.Merge([]Target{{ID: "a", LatencyMs: 10, Availability: 1}}, now)
+	}
+
+	merged := cache.Merge([]Target{{ID: "a", LatencyMs: 1000, Availability: 0}}, now)
+	if got := merged[0]; got.LatencyMs != 208 || got.Availability != 0.8 {
+		t.Fatalf("expected one outlier to move the averages by the decay, got %+v", got)
+	}
+
+	p50 := NewMemoryMetricCache(MetricConfig{Decay: 0.2, Window: 10, Latency: LatencyP50})
+	p99 := NewMemoryMetricCache(MetricConfig{Decay: 0.2, Window: 10, Latency: LatencyP99})
+	for _, cache := range []*MemoryMetricCache{p50, p99} {
+		for i := 0; i < 9; i++ {
+			cache.Merge([]Target{{ID: "a", LatencyMs: 10, Availability: 1}}, now)
+		}
+	}
+	if got := p50.Merge([]Target{{ID: "a", LatencyMs: 1000}}, now)[0].LatencyMs; got != 10 {
+		t.Fatalf("expected p50 to ignore the outlier, got %d", got)
+	}
+	if got := p99.Merge([]Target{{ID: "a", LatencyMs: 1000}}, now)[0].LatencyMs; got != 1000 {
+		t.Fatalf("expected p99 to surface the outlier, got %d", got)
+	}
+}
+
+func TestMetricCacheRecordsProbesAsSamples(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := NewMemoryMetricCache(MetricConfig{Decay: 0.5, Window: 10})
+	cache.Merge([]Target{{ID: "a", LatencyMs: 10, Availability: 1}}, now)
+
+	cache.Record("a", 30, 0, now)
+	cache.Record("a", 30, 0, now)
+	targets := cache.Targets(now)
+	if len(targets) != 1 || targets[0].LatencyMs != 25 || targets[0].Availability != 0.25 || targets[0].Samples != 3 {
+		t.Fatalf("expected probe rounds to be smoothed in, not replace the history, got %+v", targets)
+	}
+
+	cache.Record("down", -1, 0, now)
+	merged := cache.Merge([]Target{{ID: "down", LatencyMs: 40, Availability: 1}}, now)
+	if got := merged[0]; got.LatencyMs != 40 || got.Availability != 0.5 {
+		t.Fatalf("expected an unanswered probe to record availability alone, got %+v", got)
+	}
+}
+
+func TestMetricCacheObservesOutcomes(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := NewMemoryMetricCache(MetricConfig{TTL: time.Minute, Decay: 0.5})
+	cache.Merge([]Target{{ID: "a", LatencyMs: 10, Availability: 1}}, now)
+
+	cache.Observe("a", 30, true, now)
+	cache.Observe("a", -1, false, now)
+	targets := cache.Targets(now)
+	if len(targets) != 1 || targets[0].LatencyEWMAMs != 20 || targets[0].Availability != 0.5 || targets[0].Samples != 2 {
+		t.Fatalf("expected outcomes to be smoothed in, a missing latency leaving it alone, got %+v", targets)
+	}
+
+	cache.Observe("b", -1, false, now)
+	if targets := cache.Targets(now); len(targets) != 1 {
+		t.Fatalf("expected an outcome without latency not to start a target, got %+v", targets)
+	}
+}
+
+func TestMetricCacheExpiresAfterTTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := NewMemoryMetricCache(MetricConfig{TTL: time.Second, Decay: 0.5})
+	cache.Merge([]Target{{ID: "a", LatencyMs: 100, Availability: 0}}, now)
+
+	cache.Observe("a", -1, true, now.Add(2*time.Second))
+	merged := cache.Merge([]Target{{ID: "a", LatencyMs: 10, Availability: 1}}, now.Add(2*time.Second))
+	if got := merged[0]; got.LatencyMs != 10 || got.Availability != 1 {
+		t.Fatalf("expected expired metrics to be discarded, got %+v", got)
+	}
+}
+
+func TestMetricCacheOverridesUntilExpiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := NewMemoryMetricCache(MetricConfig{TTL: time.Minute, Decay: 0.5})
+	cache.Merge([]Target{{ID: "a", LatencyMs: 10, Availability: 1}, {ID: "b", LatencyMs: 20, Availability: 1}}, now)
+
+	cache.Pin("a", 500, 0.6, now.Add(10*time.Second))
+	cache.MarkDown("b", now.Add(10*time.Second))
+	merged := cache.Merge([]Target{{ID: "a", LatencyMs: 10, Availability: 1}, {ID: "b", LatencyMs: 20, Availability: 1}}, now.Add(time.Second))
+	if merged[0].LatencyMs != 500 || merged[0].Availability != 0.6 {
+		t.Fatalf("expected pinned metrics, got %+v", merged[0])
+	}
+	if merged[1].LatencyMs != 20 || merged[1].Availability != 0 {
+		t.Fatalf("expected down target to keep its latency, got %+v", merged[1])
+	}
+
+	targets := cache.Targets(now.Add(time.Second))
+	if len(targets) != 2 || targets[0].Override == nil || targets[0].Override.Kind != OverridePin || targets[0].Samples != 2 {
+		t.Fatalf("unexpected targets %+v", targets)
+	}
+
+	merged = cache.Merge([]Target{{ID: "a", LatencyMs: 10, Availability: 1}}, now.Add(10*time.Second))
+	if merged[0].LatencyMs != 10 || merged[0].Availability != 1 {
+		t.Fatalf("expected override to lapse, got %+v", merged[0])
+	}
+	if !cache.Reset("b") || cache.Reset("b") {
+		t.Fatal("expected reset to forget the target once")
+	}
+}
+
+func TestMetricCacheSnapshotRestoresFreshTargets(t *testing.T) {
+	now := time.Unix(0, 0)
+	config := MetricConfig{TTL: time.Minute, Decay: 0.5, Window: 3, Latency: LatencyP99}
+	cache := NewMemoryMetricCache(config)
+	for _, latency := range []int64{10, 20, 30, 40} {
+		cache.Merge([]Target{{ID: "a", LatencyMs: latency, Availability: 1}}, now)
+	}
+	cache.Merge([]Target{{ID: "stale", LatencyMs: 5, Availability: 1}}, now.Add(-2*time.Minute))
+	cache.MarkDown("b", now.Add(time.Hour))
+
+	path := t.TempDir() + "/metrics.json"
+	if err := WriteSnapshot(path, Snapshot{SavedAt: now, Tenants: map[string][]TargetSnapshot{"": cache.Snapshot()}}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := ReadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewMemoryMetricCache(config)
+	if n := restored.Restore(snapshot.Tenants[""], now.Add(time.Second)); n != 2 {
+		t.Fatalf("expected 2 restored targets, got %d", n)
+	}
+	if got, want := restored.Targets(now.Add(time.Second)), cache.Targets(now.Add(time.Second)); len(got) != 2 ||
+		got[0].LatencyEWMAMs != want[0].LatencyEWMAMs || got[0].LatencyP99Ms != 40 || got[0].Samples != 3 || got[1].Override == nil {
+		t.Fatalf("restored %+v, want %+v", got, want)
+	}
+	merged := restored.Merge([]Target{{ID: "a", LatencyMs: 50, Availability: 1}}, now.Add(time.Second))
+	if merged[0].LatencyMs != 50 {
+		t.Fatalf("expected the window to keep rolling from the oldest sample, got %+v", merged[0])
+	}
+
+	// Samples taken before the snapshot is loaded are newer and win.
+	probed := NewMemoryMetricCache(config)
+	probed.Record("a", 7, 1, now.Add(time.Second))
+	if n := probed.Restore(snapshot.Tenants[""], now.Add(time.Second)); n != 1 {
+		t.Fatalf("expected only b to be restored, got %d", n)
+	}
+	if got := probed.Targets(now.Add(time.Second)); got[0].LatencyMs != 7 || got[0].Samples != 1 {
+		t.Fatalf("expected the live sample to survive the restore, got %+v", got[0])
+	}
+}
@@ -0,0 +1,484 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/resp"
+)
+
+const (
+	redisMetricPrefix       = "sor:metrics:"
+	redisMetricScanCount    = 100
+	redisMetricTimeout      = 50 * time.Millisecond
+	redisMetricRetryBackoff = 5 * time.Second
+)
+
+var errRedisMetricsDown = errors.New("routing: redis metric cache is degraded")
+
+// Operations MetricScript applies to each of its keys.
+const (
+	metricOpMerge   = "merge"
+	metricOpObserve = "observe"
+	metricOpRecord  = "record"
+	metricOpPin     = "pin"
+	metricOpDown    = "down"
+	metricOpGet     = "get"
+)
+
+// MetricScript updates the smoothed metrics of targets atomically inside
+// Redis. Each key holds one target's state as JSON: the latency EWMA "e", the
+// availability EWMA "a", the latency window "l" as comma-separated
+// milliseconds with "n" the next slot to overwrite, the time "u" of the last
+// sample and an optional override "o". ARGV holds the operation, the TTL in
+// milliseconds, the decay and the window size, followed by three values per
+// key: latency and availability for merge, observe and record, a negative
+// latency meaning none was measured; latency, availability and expiry for pin
+// and down. Time is read from the Redis server, so replicas with skewed
+// clocks age samples consistently. It returns each key's resulting state, ""
+// when there is none.
+const MetricScript = `
+local op = ARGV[1]
+local ttl = tonumber(ARGV[2])
+local decay = tonumber(ARGV[3])
+local window = tonumber(ARGV[4])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local replies = {}
+for i, key in ipairs(KEYS) do
+  local latency = tonumber(ARGV[2 + 3 * i])
+  local value = tonumber(ARGV[3 + 3 * i])
+  local extra = tonumber(ARGV[4 + 3 * i])
+  local raw = redis.call('GET', key)
+  local state = {}
+  if raw then
+    state = cjson.decode(raw)
+  end
+  if state.u and now - state.u > ttl then
+    state.e, state.a, state.l, state.n, state.u = nil, nil, nil, nil, nil
+  end
+  if state.o and now >= state.o.t then
+    state.o = nil
+  end
+
+  local function sample(l, a)
+    if state.u == nil then
+      state.a, state.l, state.n = a, '', 0
+    else
+      state.a = decay * a + (1 - decay) * state.a
+    end
+    if l >= 0 then
+      if state.l == '' then
+        state.e = l
+      else
+        state.e = decay * l + (1 - decay) * state.e
+      end
+      local samples = {}
+      for s in string.gmatch(state.l, '[^,]+') do
+        table.insert(samples, s)
+      end
+      if #samples < window then
+        table.insert(samples, tostring(l))
+      else
+        samples[(state.n % #samples) + 1] = tostring(l)
+        state.n = (state.n + 1) % #samples
+      end
+      state.l = table.concat(samples, ',')
+    end
+    state.u = now
+  end
+
+  if op == 'merge' or op == 'record' or (op == 'observe' and (state.u ~= nil or latency >= 0)) then
+    sample(latency, value)
+  elseif op == 'pin' then
+    state.o = {k = 'pin', l = latency, a = value, t = extra}
+  elseif op == 'down' then
+    state.o = {k = 'down', t = extra}
+  end
+
+  local expireAt = 0
+  if state.u then
+    expireAt = state.u + ttl
+  end
+  if state.o and state.o.t > expireAt then
+    expireAt = state.o.t
+  end
+  if expireAt > now then
+    raw = cjson.encode(state)
+    if op ~= 'get' then
+      redis.call('SET', key, raw, 'PX', tostring(math.ceil(expireAt - now)))
+    end
+    replies[i] = raw
+  else
+    if op ~= 'get' then
+      redis.call('DEL', key)
+    end
+    replies[i] = ''
+  end
+end
+return replies
+`
+
+// RedisMetricCache is a MetricCache whose metrics live in Redis, so every
+// replica routes on the same view of each target. Metrics expire TTL after
+// their last sample, as in the in-memory cache. When Redis cannot be reached
+// it falls back to a local cache and retries Redis after a short backoff.
+type RedisMetricCache struct {
+	client    *resp.Client
+	namespace string
+	config    MetricConfig
+	fallback  *MemoryMetricCache
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+// NewRedisMetricCache keeps metrics under namespace, so tenants sharing a
+// server do not share metrics.
+func NewRedisMetricCache(client *resp.Client, namespace string, config MetricConfig) *RedisMetricCache {
+	config = config.withDefaults()
+	return &RedisMetricCache{
+		client:    client,
+		namespace: namespace,
+		config:    config,
+		fallback:  NewMemoryMetricCache(config),
+	}
+}
+
+// redisMetricState is the JSON MetricScript stores per target. Numbers are
+// decoded as floats because Lua does not tell integers apart.
+type redisMetricState struct {
+	LatencyEWMA  float64              `json:"e"`
+	Availability float64              `json:"a"`
+	Latencies    string               `json:"l"`
+	UpdatedAt    float64              `json:"u"`
+	Override     *redisMetricOverride `json:"o"`
+}
+
+type redisMetricOverride struct {
+	Kind         string  `json:"k"`
+	LatencyMs    float64 `json:"l"`
+	Availability float64 `json:"a"`
+	Until        float64 `json:"t"`
+}
+
+// metricArgs is the per-key part of MetricScript's ARGV.
+type metricArgs struct {
+	latencyMs    int64
+	availability float64
+	until        time.Time
+}
+
+func (c *RedisMetricCache) Merge(targets []Target, now time.Time) []Target {
+	ids := make([]string, len(targets))
+	args := make([]metricArgs, len(targets))
+	for i, target := range targets {
+		ids[i] = target.ID
+		args[i] = metricArgs{latencyMs: target.LatencyMs, availability: target.Availability}
+	}
+	states, err := c.eval(metricOpMerge, ids, args, now)
+	if err != nil {
+		return c.fallback.Merge(targets, now)
+	}
+
+	merged := make([]Target, 0, len(targets))
+	for i, target := range targets {
+		entry, override := c.decode(states[i])
+		merged = append(merged, c.config.apply(target, entry, override))
+	}
+	return merged
+}
+
+func (c *RedisMetricCache) Apply(targets []Target, now time.Time) []Target {
+	ids := make([]string, len(targets))
+	for i, target := range targets {
+		ids[i] = target.ID
+	}
+	states, err := c.eval(metricOpGet, ids, make([]metricArgs, len(ids)), now)
+	if err != nil {
+		return c.fallback.Apply(targets, now)
+	}
+
+	applied := make([]Target, 0, len(targets))
+	for i, target := range targets {
+		entry, override := c.decode(states[i])
+		applied = append(applied, c.config.apply(target, entry, override))
+	}
+	return applied
+}
+
+func (c *RedisMetricCache) Observe(targetID string, latencyMs int64, available bool, now time.Time) {
+	sample := 0.0
+	if available {
+		sample = 1
+	}
+	args := []metricArgs{{latencyMs: latencyMs, availability: sample}}
+	if _, err := c.eval(metricOpObserve, []string{targetID}, args, now); err != nil {
+		c.fallback.Observe(targetID, latencyMs, available, now)
+	}
+}
+
+func (c *RedisMetricCache) Record(targetID string, latencyMs int64, availability float64, now time.Time) {
+	args := []metricArgs{{latencyMs: latencyMs, availability: availability}}
+	if _, err := c.eval(metricOpRecord, []string{targetID}, args, now); err != nil {
+		c.fallback.Record(targetID, latencyMs, availability, now)
+	}
+}
+
+func (c *RedisMetricCache) Pin(targetID string, latencyMs int64, availability float64, until time.Time) {
+	args := []metricArgs{{latencyMs: latencyMs, availability: availability, until: until}}
+	if _, err := c.eval(metricOpPin, []string{targetID}, args, time.Now()); err != nil {
+		c.fallback.Pin(targetID, latencyMs, availability, until)
+	}
+}
+
+func (c *RedisMetricCache) MarkDown(targetID string, until time.Time) {
+	args := []metricArgs{{until: until}}
+	if _, err := c.eval(metricOpDown, []string{targetID}, args, time.Now()); err != nil {
+		c.fallback.MarkDown(targetID, until)
+	}
+}
+
+func (c *RedisMetricCache) Reset(targetID string) bool {
+	// Forget the fallback's view too, so it does not resurface on the next
+	// outage.
+	local := c.fallback.Reset(targetID)
+	if c.degraded(time.Now()) {
+		return local
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisMetricTimeout)
+	defer cancel()
+	removed, err := resp.Int64(c.client.Do(ctx, "DEL", c.key(targetID)))
+	if err != nil {
+		c.markDown(time.Now())
+		return local
+	}
+	return removed > 0
+}
+
+func (c *RedisMetricCache) Targets(now time.Time) []TargetMetrics {
+	if c.degraded(now) {
+		return c.fallback.Targets(now)
+	}
+	ids, err := c.targetIDs()
+	if err != nil {
+		c.markDown(now)
+		return c.fallback.Targets(now)
+	}
+	if len(ids) == 0 {
+		return []TargetMetrics{}
+	}
+	states, err := c.eval(metricOpGet, ids, make([]metricArgs, len(ids)), now)
+	if err != nil {
+		return c.fallback.Targets(now)
+	}
+
+	targets := make([]TargetMetrics, 0, len(ids))
+	for i, id := range ids {
+		if metrics, ok := c.metrics(id, states[i], now); ok {
+			targets = append(targets, metrics)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].TargetID < targets[j].TargetID })
+	return targets
+}
+
+// Degraded reports whether metrics are currently kept locally.
+func (c *RedisMetricCache) Degraded(now time.Time) bool {
+	return c.degraded(now)
+}
+
+func (c *RedisMetricCache) key(targetID string) string {
+	return c.keyPrefix() + targetID
+}
+
+// keyPrefix escapes the namespace so it contains neither ':' nor glob
+// characters, and a SCAN pattern for one namespace never matches another's
+// keys.
+func (c *RedisMetricCache) keyPrefix() string {
+	return redisMetricPrefix + url.QueryEscape(c.namespace) + ":"
+}
+
+// targetIDs lists the namespace's targets with SCAN, which unlike KEYS does
+// not block Redis while it walks the keyspace. SCAN may return a key more
+// than once, so IDs are deduplicated.
+func (c *RedisMetricCache) targetIDs() ([]string, error) {
+	seen := make(map[string]bool)
+	ids := []string{}
+	cursor := "0"
+	for {
+		page, next, err := c.scan(cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range page {
+			id := strings.TrimPrefix(key, c.keyPrefix())
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if next == "0" {
+			return ids, nil
+		}
+		cursor = next
+	}
+}
+
+// scan runs one SCAN step from cursor and returns the keys it matched and
+// the cursor to continue from, "0" once the keyspace has been walked.
+func (c *RedisMetricCache) scan(cursor string) ([]string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisMetricTimeout)
+	defer cancel()
+	reply, err := c.client.Do(ctx, "SCAN", cursor, "MATCH", c.keyPrefix()+"*", "COUNT", strconv.Itoa(redisMetricScanCount))
+	if err != nil {
+		return nil, "", err
+	}
+	parts, ok := reply.([]any)
+	if !ok || len(parts) != 2 {
+		return nil, "", fmt.Errorf("routing: unexpected SCAN reply %v", reply)
+	}
+	next, err := resp.String(parts[0], nil)
+	if err != nil {
+		return nil, "", err
+	}
+	items, ok := parts[1].([]any)
+	if !ok {
+		return nil, "", fmt.Errorf("routing: unexpected SCAN reply %v", reply)
+	}
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		key, err := resp.String(item, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		keys = append(keys, key)
+	}
+	return keys, next, nil
+}
+
+// eval runs MetricScript for op over targetIDs and returns their resulting
+// states. It marks Redis down on failure, and fails fast while it is.
+func (c *RedisMetricCache) eval(op string, targetIDs []string, args []metricArgs, now time.Time) ([]string, error) {
+	if c.degraded(now) {
+		return nil, errRedisMetricsDown
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisMetricTimeout)
+	defer cancel()
+
+	keys := make([]string, len(targetIDs))
+	argv := []string{
+		op,
+		strconv.FormatInt(c.config.TTL.Milliseconds(), 10),
+		strconv.FormatFloat(c.config.Decay, 'g', -1, 64),
+		strconv.Itoa(c.config.Window),
+	}
+	for i, id := range targetIDs {
+		keys[i] = c.key(id)
+		argv = append(argv,
+			strconv.FormatInt(args[i].latencyMs, 10),
+			strconv.FormatFloat(args[i].availability, 'g', -1, 64),
+			strconv.FormatInt(args[i].until.UnixMilli(), 10),
+		)
+	}
+	reply, err := c.client.EvalScript(ctx, MetricScript, keys, argv...)
+	if err != nil {
+		c.markDown(now)
+		return nil, err
+	}
+	items, ok := reply.([]any)
+	if !ok || len(items) != len(keys) {
+		c.markDown(now)
+		return nil, fmt.Errorf("routing: unexpected metric script reply %v", reply)
+	}
+	states := make([]string, len(items))
+	for i, item := range items {
+		if states[i], err = resp.String(item, nil); err != nil {
+			c.markDown(now)
+			return nil, err
+		}
+	}
+	return states, nil
+}
+
+// metrics decodes a state returned by MetricScript. It reports false when
+// the target has neither fresh samples nor an active override.
+func (c *RedisMetricCache) metrics(targetID, raw string, now time.Time) (TargetMetrics, bool) {
+	entry, override := c.decode(raw)
+	if entry == nil && override == nil {
+		return TargetMetrics{}, false
+	}
+
+	metrics := TargetMetrics{TargetID: targetID}
+	if entry != nil {
+		metrics.LatencyMs = entry.latency(c.config.Latency)
+		metrics.LatencyEWMAMs = entry.latency(LatencyEWMA)
+		metrics.LatencyP50Ms = entry.latency(LatencyP50)
+		metrics.LatencyP99Ms = entry.latency(LatencyP99)
+		metrics.Availability = entry.availability
+		metrics.Samples = len(entry.latencies.samples)
+		metrics.UpdatedAt = entry.updatedAt
+		metrics.AgeMs = now.Sub(entry.updatedAt).Milliseconds()
+	}
+	if override != nil {
+		metrics.LatencyMs, metrics.Availability = override.apply(metrics.LatencyMs)
+		metrics.Override = override
+	}
+	return metrics, true
+}
+
+// decode splits a state returned by MetricScript into the target's samples
+// and override, each nil when there is none.
+func (c *RedisMetricCache) decode(raw string) (*metricEntry, *Override) {
+	if raw == "" {
+		return nil, nil
+	}
+	var state redisMetricState
+	if err := json.Unmarshal([]byte(raw), &state); err != nil {
+		return nil, nil
+	}
+
+	var entry *metricEntry
+	if state.UpdatedAt > 0 {
+		entry = &metricEntry{
+			latencyEWMA:  state.LatencyEWMA,
+			availability: state.Availability,
+			updatedAt:    time.UnixMilli(int64(state.UpdatedAt)).UTC(),
+		}
+		for _, field := range strings.Split(state.Latencies, ",") {
+			if sample, err := strconv.ParseInt(field, 10, 64); err == nil {
+				entry.latencies.samples = append(entry.latencies.samples, sample)
+			}
+		}
+	}
+	var override *Override
+	if state.Override != nil {
+		override = &Override{
+			Kind:         state.Override.Kind,
+			LatencyMs:    int64(state.Override.LatencyMs),
+			Availability: state.Override.Availability,
+			Until:        time.UnixMilli(int64(state.Override.Until)).UTC(),
+		}
+	}
+	return entry, override
+}
+
+func (c *RedisMetricCache) degraded(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return now.Before(c.downUntil)
+}
+
+func (c *RedisMetricCache) markDown(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.downUntil = now.Add(redisMetricRetryBackoff)
+}
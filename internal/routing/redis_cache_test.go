@@ -0,0 +1,250 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/resp"
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/resp/resptest"
+)
+
+// emulateMetricScript mirrors MetricScript for the RESP stand-in, which
+// cannot run Lua.
+func emulateMetricScript(s *resptest.Server, keys, args []string) any {
+	op := args[0]
+	ttl, _ := strconv.ParseFloat(args[1], 64)
+	decay, _ := strconv.ParseFloat(args[2], 64)
+	window, _ := strconv.Atoi(args[3])
+	now := float64(s.Now().UnixMilli())
+
+	replies := make([]any, len(keys))
+	for i, key := range keys {
+		latency, _ := strconv.ParseFloat(args[4+3*i], 64)
+		value, _ := strconv.ParseFloat(args[5+3*i], 64)
+		extra, _ := strconv.ParseFloat(args[6+3*i], 64)
+
+		state := map[string]any{}
+		if raw, ok := s.Get(key); ok {
+			_ = json.Unmarshal([]byte(raw), &state)
+		}
+		if u, ok := state["u"].(float64); ok && now-u > ttl {
+			for _, field := range []string{"e", "a", "l", "n", "u"} {
+				delete(state, field)
+			}
+		}
+		if o, ok := state["o"].(map[string]any); ok && now >= o["t"].(float64) {
+			delete(state, "o")
+		}
+
+		sample := func(l, a float64) {
+			if _, ok := state["u"]; !ok {
+				state["a"], state["l"], state["n"] = a, "", 0.0
+			} else {
+				state["a"] = decay*a + (1-decay)*state["a"].(float64)
+			}
+			if l >= 0 {
+				var samples []string
+				if state["l"].(string) == "" {
+					state["e"] = l
+				} else {
+					state["e"] = decay*l + (1-decay)*state["e"].(float64)
+					samples = strings.Split(state["l"].(string), ",")
+				}
+				next := int(state["n"].(float64))
+				if len(samples) < window {
+					samples = append(samples, strconv.FormatFloat(l, 'f', -1, 64))
+				} else {
+					samples[next%len(samples)] = strconv.FormatFloat(l, 'f', -1, 64)
+					state["n"] = float64((next + 1) % len(samples))
+				}
+				state["l"] = strings.Join(samples, ",")
+			}
+			state["u"] = now
+		}
+
+		_, cached := state["u"]
+		switch {
+		case op == metricOpMerge || op == metricOpRecord || (op == metricOpObserve && (cached || latency >= 0)):
+			sample(latency, value)
+		case op == metricOpPin:
+			state["o"] = map[string]any{"k": "pin", "l": latency, "a": value, "t": extra}
+		case op == metricOpDown:
+			state["o"] = map[string]any{"k": "down", "t": extra}
+		}
+
+		expireAt := 0.0
+		if u, ok := state["u"].(float64); ok {
+			expireAt = u + ttl
+		}
+		if o, ok := state["o"].(map[string]any); ok && o["t"].(float64) > expireAt {
+			expireAt = o["t"].(float64)
+		}
+		if expireAt > now {
+			raw, _ := json.Marshal(state)
+			if op != metricOpGet {
+				s.Set(key, string(raw), time.Duration(math.Ceil(expireAt-now))*time.Millisecond)
+			}
+			replies[i] = string(raw)
+		} else {
+			if op != metricOpGet {
+				s.Delete(key)
+			}
+			replies[i] = ""
+		}
+	}
+	return replies
+}
+
+func TestRedisMetricCacheSharesMetricsAcrossReplicas(t *testing.T) {
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("start stand-in: %v", err)
+	}
+	server.RegisterScript(MetricScript, emulateMetricScript)
+
+	config := MetricConfig{TTL: time.Minute, Decay: 0.5, Window: 10}
+	replicaA := NewRedisMetricCache(resp.NewClient(server.Addr(), resp.Options{}), "acme", config)
+	replicaB := NewRedisMetricCache(resp.NewClient(server.Addr(), resp.Options{}), "acme", config)
+	otherTenant := NewRedisMetricCache(resp.NewClient(server.Addr(), resp.Options{}), "acme:b", config)
+
+	now := time.Now()
+	replicaA.Merge([]Target{{ID: "a", LatencyMs: 10, Availability: 1}}, now)
+	replicaB.Observe("a", 30, false, now)
+	merged := replicaA.Merge([]Target{{ID: "a", LatencyMs: 20, Availability: 1}}, now)
+	if got := merged[0]; got.LatencyMs != 20 || got.Availability != 0.75 {
+		t.Fatalf("expected replicas to smooth one shared series, got %+v", got)
+	}
+
+	replicaB.Record("a", 40, 1, now)
+	if targets := replicaA.Targets(now); len(targets) != 1 || targets[0].LatencyMs != 30 || targets[0].Availability != 0.875 {
+		t.Fatalf("expected a probe sample to be smoothed into the shared series, got %+v", targets)
+	}
+
+	replicaB.MarkDown("a", now.Add(time.Minute))
+	targets := replicaA.Targets(now)
+	if len(targets) != 1 || targets[0].Availability != 0 || targets[0].Samples != 4 || targets[0].Override == nil {
+		t.Fatalf("expected a shared override, got %+v", targets)
+	}
+	if targets := otherTenant.Targets(now); len(targets) != 0 {
+		t.Fatalf("expected namespaces to be isolated, got %+v", targets)
+	}
+
+	later := now.Add(2 * time.Minute)
+	server.SetClock(func() time.Time { return later })
+	if targets := replicaA.Targets(later); len(targets) != 0 {
+		t.Fatalf("expected metrics to expire after TTL, got %+v", targets)
+	}
+
+	server.Close()
+
+	merged = replicaA.Merge([]Target{{ID: "a", LatencyMs: 40, Availability: 1}}, later)
+	if merged[0].LatencyMs != 40 || !replicaA.Degraded(later) {
+		t.Fatalf("expected a local fallback once redis failed, got %+v", merged[0])
+	}
+}
+
+func TestRedisMetricCacheListsTargetsAcrossScanPages(t *testing.T) {
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("start stand-in: %v", err)
+	}
+	defer server.Close()
+	server.RegisterScript(MetricScript, emulateMetricScript)
+	cache := NewRedisMetricCache(resp.NewClient(server.Addr(), resp.Options{}), "acme", MetricConfig{TTL: time.Minute})
+	other := NewRedisMetricCache(resp.NewClient(server.Addr(), resp.Options{}), "globex", MetricConfig{TTL: time.Minute})
+
+	targets := make([]Target, 3*redisMetricScanCount)
+	for i := range targets {
+		targets[i] = Target{ID: "venue-" + strconv.Itoa(i), LatencyMs: 5, Availability: 1}
+	}
+	now := time.Now()
+	cache.Merge(targets, now)
+	other.Merge(targets[:10], now)
+	if listed := cache.Targets(now); len(listed) != len(targets) {
+		t.Fatalf("expected %d targets across SCAN pages, got %d", len(targets), len(listed))
+	}
+}
+
+func TestRedisMetricCacheMergeKeepsTargetLatencyWithoutSamples(t *testing.T) {
+	server, err := resptest.NewServer()
+	if err != nil {
+		t.Fatalf("start stand-in: %v", err)
+	}
+	defer server.Close()
+	now := time.Now()
+	until := float64(now.Add(time.Minute).UnixMilli())
+	states := map[string]string{
+		"down":  fmt.Sprintf(`{"o":{"k":"down","t":%v}}`, until),
+		"empty": fmt.Sprintf(`{"a":0.5,"l":"","n":0,"u":%v}`, float64(now.UnixMilli())),
+	}
+	server.RegisterScript(MetricScript, func(_ *resptest.Server, keys, _ []string) any {
+		replies := make([]any, len(keys))
+		for i, key := range keys {
+			replies[i] = states[key[strings.LastIndex(key, ":")+1:]]
+		}
+		return replies
+	})
+	cache := NewRedisMetricCache(resp.NewClient(server.Addr(), resp.Options{}), "acme", MetricConfig{TTL: time.Minute})
+
+	merged := cache.Merge([]Target{
+		{ID: "down", LatencyMs: 25, Availability: 1},
+		{ID: "empty", LatencyMs: 15, Availability: 1},
+	}, now)
+	if got := merged[0]; got.LatencyMs != 25 || got.Availability != 0 {
+		t.Fatalf("expected a down target to keep its own latency, got %+v", got)
+	}
+	if got := merged[1]; got.LatencyMs != 15 || got.Availability != 0.5 {
+		t.Fatalf("expected a target without latency samples to keep its own latency, got %+v", got)
+	}
+}
+
+// TestRedisMetricScriptOnRedis runs MetricScript on the Redis at REDIS_ADDR,
+// which the stand-in only emulates. It is skipped when none is reachable.
+func TestRedisMetricScriptOnRedis(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	client := resp.NewClient(addr, resp.Options{})
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := client.Do(ctx, "PING"); err != nil {
+		t.Skipf("redis at %s is unreachable: %v", addr, err)
+	}
+
+	namespace := "test-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	config := MetricConfig{TTL: time.Minute, Decay: 0.5, Window: 10}
+	replicaA := NewRedisMetricCache(client, namespace, config)
+	replicaB := NewRedisMetricCache(client, namespace, config)
+	defer replicaA.Reset("a")
+
+	now := time.Now()
+	replicaA.Merge([]Target{{ID: "a", LatencyMs: 10, Availability: 1}}, now)
+	replicaB.Observe("a", 30, false, now)
+	merged := replicaA.Merge([]Target{{ID: "a", LatencyMs: 20, Availability: 1}}, now)
+	if got := merged[0]; got.LatencyMs != 20 || got.Availability != 0.75 {
+		t.Fatalf("expected replicas to smooth one shared series, got %+v", got)
+	}
+	replicaB.Observe("b", -1, false, now)
+	replicaB.Record("a", 40, 1, now)
+	if targets := replicaA.Targets(now); len(targets) != 1 || targets[0].LatencyMs != 30 || targets[0].Availability != 0.875 {
+		t.Fatalf("expected a probe sample to be smoothed into the shared series, got %+v", targets)
+	}
+
+	replicaB.MarkDown("a", now.Add(time.Minute))
+	targets := replicaA.Targets(now)
+	if len(targets) != 1 || targets[0].Availability != 0 || targets[0].Samples != 4 || targets[0].Override == nil {
+		t.Fatalf("expected a shared override, got %+v", targets)
+	}
+	if replicaA.Degraded(time.Now()) {
+		t.Fatalf("expected redis to stay up")
+	}
+}
@@ -1,10 +1,16 @@
 package routing
 
-import "errors"
+import (
+    "errors"
+    "time"
+)
 
 const minAvailability = 0.5
 
-var ErrNoTargets = errors.New("no targets provided")
+var (
+    ErrNoTargets       = errors.New("no targets provided")
+    ErrAllCircuitsOpen = errors.New("all targets have an open circuit")
+)
 
 type Target struct {
     ID           string
@@ -12,62 +18,217 @@ type Target struct {
     LatencyMs    int64
     Availability float64
     Priority     int
+    FeeBps       float64
+    FillRate     float64
+    // Capacity is the largest quantity the target accepts in one order.
+    // Zero means the target did not declare a limit.
+    Capacity int64
 }
 
 type Decision struct {
-    Target   Target
-    Score    float64
-    Fallback bool
-    Reason   string
+    Target    Target
+    Score     float64
+    Fallback  bool
+    Reason    string
+    Strategy  string
+    Breakdown *Breakdown
+    // DecidedBy is the criterion that put the winner ahead of the runner-up.
+    DecidedBy string
+    // Candidates lists every target considered, ranked ones first in rank
+    // order followed by the ones filtered out before ranking.
+    Candidates []Candidate
+}
+
+// Candidate explains how one target fared in a decision. Filtered targets
+// never compete, so they carry a FilteredBy reason instead of a rank and
+// score.
+type Candidate struct {
+    Target     Target
+    Rank       int
+    Score      float64
+    Breakdown  *Breakdown
+    DecidedBy  string
+    FilteredBy string
+}
+
+// Options tunes a single routing decision. The zero value routes with the
+// default best-latency strategy and no circuit breakers.
+type Options struct {
+    Strategy Strategy
+    // Breakers, when set, excludes targets whose circuit is open.
+    Breakers *Breakers
+    // Now defaults to time.Now and only matters for circuit breakers.
+    Now time.Time
+}
+
+func (o Options) strategy() Strategy {
+    if o.Strategy == nil {
+        return BestLatency{}
+    }
+    return o.Strategy
+}
+
+func (o Options) now() time.Time {
+    if o.Now.IsZero() {
+        return time.Now()
+    }
+    return o.Now
 }
 
 func SelectTarget(targets []Target) (Decision, error) {
+    return Select(targets, Options{})
+}
+
+func Select(targets []Target, opts Options) (Decision, error) {
     if len(targets) == 0 {
         return Decision{}, ErrNoTargets
     }
 
-    eligible := make([]Target, 0, len(targets))
-    for _, target := range targets {
-        if target.Availability >= minAvailability {
-            eligible = append(eligible, target)
-        }
+    strategy := opts.strategy()
+    now := opts.now()
+
+    allowed, tripped := opts.Breakers.partition(targets, now)
+    if len(allowed) == 0 {
+        return Decision{}, ErrAllCircuitsOpen
     }
 
-    if len(eligible) == 0 {
-        fallback := pickBest(targets)
-        return Decision{
-            Target:   fallback,
-            Score:    float64(fallback.LatencyMs),
-            Fallback: true,
-            Reason:   "fallback-no-healthy-targets",
-        }, nil
+    pool := healthyTargets(allowed)
+    fallback := len(pool) == 0
+    if fallback {
+        pool = allowed
+    }
+
+    // The partition above only peeks at the circuits; the winner is the
+    // first ranked target whose trial slot can still be claimed, since a
+    // concurrent decision may have taken it in the meantime.
+    ranked := strategy.Rank(pool)
+    for len(ranked) > 0 && !opts.Breakers.tryAcquire(ranked[0].Target.ID, now) {
+        tripped = append(tripped, ranked[0].Target)
+        ranked = ranked[1:]
+    }
+    if len(ranked) == 0 {
+        return Decision{}, ErrAllCircuitsOpen
+    }
+    best := ranked[0]
+
+    reason := strategy.Name()
+    switch {
+    case fallback:
+        reason = "fallback-no-healthy-targets"
+    case trippedAhead(strategy, pool, tripped)[best.Target.ID]:
+        reason = "circuit-open"
+    }
+
+    candidates := explainCandidates(allowed, ranked, fallback)
+    for _, target := range tripped {
+        candidates = append(candidates, Candidate{
+            Target:     target,
+            FilteredBy: "circuit-open",
+        })
     }
 
-    best := pickBest(eligible)
     return Decision{
-        Target:   best,
-        Score:    float64(best.LatencyMs),
-        Fallback: false,
-        Reason:   "best-latency",
+        Target:     best.Target,
+        Score:      best.Score,
+        Fallback:   fallback,
+        Reason:     reason,
+        Strategy:   strategy.Name(),
+        Breakdown:  best.Breakdown,
+        DecidedBy:  best.DecidedBy,
+        Candidates: candidates,
     }, nil
 }
 
-func pickBest(targets []Target) Target {
-    best := targets[0]
-    for _, candidate := range targets[1:] {
-        if candidate.LatencyMs < best.LatencyMs {
-            best = candidate
+func explainCandidates(targets []Target, ranked []Ranked, fallback bool) []Candidate {
+    candidates := make([]Candidate, 0, len(targets))
+    for idx, entry := range ranked {
+        candidates = append(candidates, Candidate{
+            Target:    entry.Target,
+            Rank:      idx + 1,
+            Score:     entry.Score,
+            Breakdown: entry.Breakdown,
+            DecidedBy: entry.DecidedBy,
+        })
+    }
+    if fallback {
+        return candidates
+    }
+    for _, target := range targets {
+        if target.Availability < minAvailability {
+            candidates = append(candidates, Candidate{
+                Target:     target,
+                FilteredBy: "below-min-availability",
+            })
+        }
+    }
+    return candidates
+}
+
+// trippedAhead reports, for each target of pool, whether a healthy target
+// excluded by its circuit would have ranked ahead of it had it competed.
+// Ties keep the pool target ahead, so only a strictly better excluded target
+// counts. Ranking uses preview so stateful strategies are not advanced.
+func trippedAhead(strategy Strategy, pool, tripped []Target) map[string]bool {
+    behind := make(map[string]bool, len(pool))
+    if len(tripped) == 0 {
+        return behind
+    }
+    excluded := make(map[string]bool, len(tripped))
+    for _, target := range tripped {
+        excluded[target.ID] = true
+    }
+    contenders := append([]Target(nil), pool...)
+    listed := make(map[string]bool, len(pool))
+    for _, target := range pool {
+        listed[target.ID] = true
+    }
+    for _, target := range healthyTargets(tripped) {
+        if !listed[target.ID] {
+            contenders = append(contenders, target)
+        }
+    }
+
+    passed := false
+    for _, entry := range preview(strategy, contenders) {
+        if excluded[entry.Target.ID] {
+            passed = true
             continue
         }
-        if candidate.LatencyMs == best.LatencyMs {
-            if candidate.Availability > best.Availability {
-                best = candidate
-                continue
-            }
-            if candidate.Availability == best.Availability && candidate.Priority < best.Priority {
-                best = candidate
-            }
+        behind[entry.Target.ID] = passed
+    }
+    return behind
+}
+
+func healthyTargets(targets []Target) []Target {
+    eligible := make([]Target, 0, len(targets))
+    for _, target := range targets {
+        if target.Availability >= minAvailability {
+            eligible = append(eligible, target)
+        }
+    }
+    return eligible
+}
+
+// compareLatency orders targets by latency, then higher availability, then
+// lower priority value, and reports which of those criteria decided. Full
+// ties compare as equal so stable sorts keep the caller's order.
+func compareLatency(a, b Target) (int, string) {
+    switch {
+    case a.LatencyMs != b.LatencyMs:
+        if a.LatencyMs < b.LatencyMs {
+            return -1, "latency"
+        }
+        return 1, "latency"
+    case a.Availability != b.Availability:
+        if a.Availability > b.Availability {
+            return -1, "availability"
+        }
+        return 1, "availability"
+    case a.Priority != b.Priority:
+        if a.Priority < b.Priority {
+            return -1, "priority"
         }
+        return 1, "priority"
     }
-    return best
+    return 0, "input-order"
 }
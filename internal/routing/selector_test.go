@@ -1,6 +1,11 @@
 package routing
 
-import "testing"
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestSelectTargetFallbackIsDeterministic(t *testing.T) {
 	targets := []Target{
@@ -37,3 +42,340 @@ func TestSelectTargetRejectsEmptyTargets(t *testing.T) {
 		t.Fatalf("expected ErrNoTargets, got %v", err)
 	}
 }
+
+func TestSelectUsesRequestedStrategy(t *testing.T) {
+	targets := []Target{
+		{ID: "fast", LatencyMs: 5, Availability: 0.9, Priority: 3},
+		{ID: "preferred", LatencyMs: 40, Availability: 0.9, Priority: 1},
+	}
+
+	decision, err := Select(targets, Options{Strategy: PriorityFirst{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decision.Target.ID != "preferred" {
+		t.Fatalf("expected preferred target, got %s", decision.Target.ID)
+	}
+	if decision.Strategy != "priority-first" || decision.Reason != "priority-first" {
+		t.Fatalf("unexpected strategy/reason: %s/%s", decision.Strategy, decision.Reason)
+	}
+}
+
+func TestRoundRobinRotatesThroughEligibleTargets(t *testing.T) {
+	targets := []Target{
+		{ID: "c", LatencyMs: 10, Availability: 0.9},
+		{ID: "a", LatencyMs: 10, Availability: 0.9},
+		{ID: "down", LatencyMs: 1, Availability: 0.1},
+		{ID: "b", LatencyMs: 10, Availability: 0.9},
+	}
+	strategy := NewRoundRobin()
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		decision, err := Select(targets, Options{Strategy: strategy})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, decision.Target.ID)
+	}
+	want := []string{"a", "b", "c", "a"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("rotation mismatch: got %v want %v", got, want)
+		}
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	registry := DefaultRegistry()
+
+	strategy, err := registry.Lookup("")
+	if err != nil || strategy.Name() != DefaultStrategy {
+		t.Fatalf("expected default strategy, got %v (%v)", strategy, err)
+	}
+	if _, err := registry.Lookup("nope"); err != ErrUnknownStrategy {
+		t.Fatalf("expected ErrUnknownStrategy, got %v", err)
+	}
+}
+
+func TestWeightedScoreBreakdownExplainsWinner(t *testing.T) {
+	targets := []Target{
+		{ID: "fast-expensive", LatencyMs: 5, Availability: 0.9, FeeBps: 3, FillRate: 0.6},
+		{ID: "slow-cheap", LatencyMs: 25, Availability: 0.95, FeeBps: 0.5, FillRate: 0.98},
+	}
+	weights := Weights{Latency: 0.2, Availability: 0.2, Fee: 0.3, FillRate: 0.3}
+
+	decision, err := Select(targets, Options{Strategy: NewWeightedScore(weights)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decision.Target.ID != "slow-cheap" {
+		t.Fatalf("expected slow-cheap to win, got %s", decision.Target.ID)
+	}
+	if decision.Breakdown == nil {
+		t.Fatalf("expected a factor breakdown")
+	}
+	b := decision.Breakdown
+	sum := b.Latency + b.Availability + b.Priority + b.Fee + b.FillRate
+	if diff := sum - decision.Score; diff > 1e-9 || diff < -1e-9 {
+		t.Fatalf("breakdown sums to %f, score is %f", sum, decision.Score)
+	}
+	if b.Latency != 0 {
+		t.Fatalf("slowest target should get no latency credit, got %f", b.Latency)
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	weights, err := ParseWeights("latency=0.6, fee=0.4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if weights.Latency != 0.6 || weights.Fee != 0.4 || weights.Availability != 0 {
+		t.Fatalf("unexpected weights: %+v", weights)
+	}
+	for _, raw := range []string{"", "latency=-1", "speed=1", "latency"} {
+		if _, err := ParseWeights(raw); err != ErrInvalidWeights {
+			t.Fatalf("expected ErrInvalidWeights for %q, got %v", raw, err)
+		}
+	}
+}
+
+func TestSplitFillsTargetsByRankUpToCapacity(t *testing.T) {
+	targets := []Target{
+		{ID: "slow", LatencyMs: 30, Availability: 0.9},
+		{ID: "fast", LatencyMs: 5, Availability: 0.9, Capacity: 400},
+		{ID: "mid", LatencyMs: 10, Availability: 0.9, Capacity: 350},
+		{ID: "down", LatencyMs: 1, Availability: 0.2, Capacity: 10000},
+	}
+
+	split, err := Split(targets, 1000, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []struct {
+		id       string
+		quantity int64
+		reason   string
+	}{
+		{"fast", 400, "capacity-filled"},
+		{"mid", 350, "capacity-filled"},
+		{"slow", 250, "remainder"},
+	}
+	if len(split.Children) != len(want) {
+		t.Fatalf("expected %d children, got %+v", len(want), split.Children)
+	}
+	for i, child := range split.Children {
+		if child.Target.ID != want[i].id || child.Quantity != want[i].quantity || child.Reason != want[i].reason {
+			t.Fatalf("child %d: got %s/%d/%s want %+v", i, child.Target.ID, child.Quantity, child.Reason, want[i])
+		}
+	}
+}
+
+func TestSplitReportsInsufficientCapacity(t *testing.T) {
+	targets := []Target{
+		{ID: "a", LatencyMs: 5, Availability: 0.9, Capacity: 100},
+		{ID: "b", LatencyMs: 6, Availability: 0.9, Capacity: 100},
+	}
+	if _, err := Split(targets, 500, Options{}); err != ErrInsufficientCapacity {
+		t.Fatalf("expected ErrInsufficientCapacity, got %v", err)
+	}
+}
+
+func TestSelectExplainsCandidatesAndTieBreaker(t *testing.T) {
+	targets := []Target{
+		{ID: "b", LatencyMs: 20, Availability: 0.8, Priority: 2},
+		{ID: "down", LatencyMs: 1, Availability: 0.3},
+		{ID: "a", LatencyMs: 20, Availability: 0.9, Priority: 5},
+	}
+
+	decision, err := SelectTarget(targets)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decision.Target.ID != "a" || decision.DecidedBy != "availability" {
+		t.Fatalf("expected a to win on availability, got %s by %s", decision.Target.ID, decision.DecidedBy)
+	}
+	if len(decision.Candidates) != 3 {
+		t.Fatalf("expected 3 candidates, got %d", len(decision.Candidates))
+	}
+	last := decision.Candidates[2]
+	if last.Target.ID != "down" || last.FilteredBy != "below-min-availability" || last.Rank != 0 {
+		t.Fatalf("expected filtered candidate last, got %+v", last)
+	}
+	if decision.Candidates[1].Target.ID != "b" || decision.Candidates[1].Rank != 2 {
+		t.Fatalf("expected b ranked second, got %+v", decision.Candidates[1])
+	}
+}
+
+func TestSelectSkipsTargetsWithOpenCircuit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breakers := NewBreakers(BreakerConfig{FailureThreshold: 2, OpenFor: time.Minute})
+	breakers.Record("fast", false, now)
+	breakers.Record("fast", false, now)
+
+	targets := []Target{
+		{ID: "fast", LatencyMs: 5, Availability: 0.9},
+		{ID: "slow", LatencyMs: 50, Availability: 0.9},
+	}
+
+	decision, err := Select(targets, Options{Breakers: breakers, Now: now})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decision.Target.ID != "slow" || decision.Reason != "circuit-open" {
+		t.Fatalf("expected slow via circuit-open, got %s/%s", decision.Target.ID, decision.Reason)
+	}
+
+	later := now.Add(time.Minute)
+	if state := breakers.State("fast", later); state != BreakerHalfOpen {
+		t.Fatalf("expected half-open after cooldown, got %s", state)
+	}
+	trial, err := Select(targets, Options{Breakers: breakers, Now: later})
+	if err != nil || trial.Target.ID != "fast" {
+		t.Fatalf("expected trial order to fast, got %s (%v)", trial.Target.ID, err)
+	}
+	again, err := Select(targets, Options{Breakers: breakers, Now: later})
+	if err != nil || again.Target.ID != "slow" {
+		t.Fatalf("expected only one trial while half-open, got %s (%v)", again.Target.ID, err)
+	}
+	if state := breakers.Record("fast", true, later); state != BreakerClosed {
+		t.Fatalf("expected closed after successful trial, got %s", state)
+	}
+}
+
+func TestCircuitOpenReasonOnlyWhenTrippedTargetRankedAhead(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breakers := NewBreakers(BreakerConfig{FailureThreshold: 1, OpenFor: time.Minute})
+	breakers.Record("slow", false, now)
+	breakers.Record("down", false, now)
+
+	targets := []Target{
+		{ID: "fast", LatencyMs: 5, Availability: 0.9, Capacity: 100},
+		{ID: "slow", LatencyMs: 50, Availability: 0.9},
+		{ID: "down", LatencyMs: 1, Availability: 0.2},
+	}
+	decision, err := Select(targets, Options{Breakers: breakers, Now: now})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decision.Target.ID != "fast" || decision.Reason != "best-latency" {
+		t.Fatalf("expected fast by best-latency, got %s/%s", decision.Target.ID, decision.Reason)
+	}
+
+	split, err := Split(targets[:2], 100, Options{Breakers: breakers, Now: now})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if split.Children[0].Reason != "best-latency" {
+		t.Fatalf("expected a slower tripped target not to change the reason, got %+v", split.Children)
+	}
+
+	breakers.Record("fast", false, now)
+	targets = append(targets, Target{ID: "mid", LatencyMs: 20, Availability: 0.9, Capacity: 100})
+	targets = append(targets, Target{ID: "spare", LatencyMs: 30, Availability: 0.9})
+	split, err = Split(targets, 150, Options{Breakers: breakers, Now: now})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(split.Children) != 2 || split.Children[0].Reason != "circuit-open" || split.Children[1].Reason != "circuit-open" {
+		t.Fatalf("expected children behind the tripped fast target to report circuit-open, got %+v", split.Children)
+	}
+}
+
+func TestRoundRobinAdvancesOncePerDecisionWithTrippedTargets(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breakers := NewBreakers(BreakerConfig{FailureThreshold: 1, OpenFor: time.Minute})
+	breakers.Record("a", false, now)
+
+	targets := []Target{
+		{ID: "a", Availability: 0.9},
+		{ID: "b", Availability: 0.9},
+		{ID: "c", Availability: 0.9},
+	}
+	strategy := NewRoundRobin()
+	var got []string
+	for i := 0; i < 4; i++ {
+		decision, err := Select(targets, Options{Strategy: strategy, Breakers: breakers, Now: now})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, decision.Target.ID)
+	}
+	if want := "b c b c"; strings.Join(got, " ") != want {
+		t.Fatalf("expected rotation %q, got %q", want, strings.Join(got, " "))
+	}
+}
+
+func TestHalfOpenCircuitAdmitsOneConcurrentTrial(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breakers := NewBreakers(BreakerConfig{FailureThreshold: 1, OpenFor: time.Minute})
+	breakers.Record("fast", false, now)
+	later := now.Add(time.Minute)
+
+	targets := []Target{
+		{ID: "fast", LatencyMs: 5, Availability: 0.9},
+		{ID: "slow", LatencyMs: 50, Availability: 0.9},
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		trials int
+	)
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			decision, err := Select(targets, Options{Breakers: breakers, Now: later})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if decision.Target.ID == "fast" {
+				mu.Lock()
+				trials++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if trials != 1 {
+		t.Fatalf("expected exactly one trial order to the half-open target, got %d", trials)
+	}
+}
+
+func TestSplitReleasesTrialWhenCapacityFallsShort(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breakers := NewBreakers(BreakerConfig{FailureThreshold: 1, OpenFor: time.Minute})
+	breakers.Record("a", false, now)
+	later := now.Add(time.Minute)
+
+	targets := []Target{
+		{ID: "a", LatencyMs: 5, Availability: 0.9, Capacity: 100},
+		{ID: "b", LatencyMs: 6, Availability: 0.9, Capacity: 100},
+	}
+	if _, err := Split(targets, 500, Options{Breakers: breakers, Now: later}); err != ErrInsufficientCapacity {
+		t.Fatalf("expected ErrInsufficientCapacity, got %v", err)
+	}
+	split, err := Split(targets, 150, Options{Breakers: breakers, Now: later})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if split.Children[0].Target.ID != "a" {
+		t.Fatalf("expected the unused trial to stay available, got %+v", split.Children)
+	}
+	if _, err := Split(targets, 150, Options{Breakers: breakers, Now: later}); err != ErrInsufficientCapacity {
+		t.Fatalf("expected the claimed trial to keep a out of the next split, got %v", err)
+	}
+}
+
+func TestSelectFailsWhenEveryCircuitIsOpen(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breakers := NewBreakers(BreakerConfig{FailureThreshold: 1, OpenFor: time.Minute})
+	breakers.Record("only", false, now)
+
+	_, err := Select([]Target{{ID: "only", Availability: 1}}, Options{Breakers: breakers, Now: now})
+	if err != ErrAllCircuitsOpen {
+		t.Fatalf("expected ErrAllCircuitsOpen, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package routing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/atomicfile"
+)
+
+// Snapshotter is a MetricCache that can be saved and restored across
+// restarts. Caches kept outside the process, such as RedisMetricCache, need
+// not implement it.
+type Snapshotter interface {
+	Snapshot() []TargetSnapshot
+	// Restore loads targets, skipping samples older than TTL, expired
+	// overrides and targets the cache already holds, whose state is newer
+	// than the snapshot. It returns how many targets it restored.
+	Restore(targets []TargetSnapshot, now time.Time) int
+}
+
+// TargetSnapshot is the saved state of one target. Latencies are the window
+// of raw samples, oldest first.
+type TargetSnapshot struct {
+	TargetID     string    `json:"targetId"`
+	LatencyEWMA  float64   `json:"latencyEwma"`
+	Availability float64   `json:"availability"`
+	Latencies    []int64   `json:"latencies,omitempty"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	Override     *Override `json:"override,omitempty"`
+}
+
+// Snapshot is the saved state of every tenant's metric cache, keyed by
+// tenant ID.
+type Snapshot struct {
+	SavedAt time.Time                   `json:"savedAt"`
+	Tenants map[string][]TargetSnapshot `json:"tenants"`
+}
+
+func (c *MemoryMetricCache) Snapshot() []TargetSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	byID := make(map[string]*TargetSnapshot)
+	for id, entry := range c.metrics {
+		byID[id] = &TargetSnapshot{
+			TargetID:     id,
+			LatencyEWMA:  entry.latencyEWMA,
+			Availability: entry.availability,
+			Latencies:    entry.latencies.ordered(),
+			UpdatedAt:    entry.updatedAt,
+		}
+	}
+	for id, override := range c.overrides {
+		target, ok := byID[id]
+		if !ok {
+			target = &TargetSnapshot{TargetID: id}
+			byID[id] = target
+		}
+		target.Override = &override
+	}
+
+	targets := make([]TargetSnapshot, 0, len(byID))
+	for _, target := range byID {
+		targets = append(targets, *target)
+	}
+	return targets
+}
+
+func (c *MemoryMetricCache) Restore(targets []TargetSnapshot, now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	restored := 0
+	for _, target := range targets {
+		_, cached := c.metrics[target.TargetID]
+		fresh := !cached && !target.UpdatedAt.IsZero() && now.Sub(target.UpdatedAt) <= c.config.TTL
+		if fresh {
+			entry := &metricEntry{
+				latencyEWMA:  target.LatencyEWMA,
+				availability: target.Availability,
+				updatedAt:    target.UpdatedAt,
+			}
+			for _, sample := range target.Latencies {
+				entry.latencies.add(sample, c.config.Window)
+			}
+			c.metrics[target.TargetID] = entry
+		}
+		_, pinned := c.overrides[target.TargetID]
+		overridden := !pinned && target.Override != nil && now.Before(target.Override.Until)
+		if overridden {
+			c.overrides[target.TargetID] = *target.Override
+		}
+		if fresh || overridden {
+			restored++
+		}
+	}
+	return restored
+}
+
+// ReadSnapshot loads the snapshot at path, returning an empty one if the file
+// does not exist.
+func ReadSnapshot(path string) (Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, nil
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return Snapshot{}, fmt.Errorf("routing: %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// WriteSnapshot saves snapshot to path atomically, so a crash never leaves a
+// partial snapshot.
+func WriteSnapshot(path string, snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, append(data, '\n'))
+}
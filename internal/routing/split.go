@@ -0,0 +1,103 @@
+package routing
+
+import "errors"
+
+var (
+    ErrInvalidQuantity      = errors.New("quantity must be greater than 0")
+    ErrInsufficientCapacity = errors.New("targets lack capacity for the requested quantity")
+)
+
+// Allocation is one child order of a split decision.
+type Allocation struct {
+    Target   Target
+    Quantity int64
+    Score    float64
+    Reason   string
+}
+
+type SplitDecision struct {
+    Children []Allocation
+    Fallback bool
+    Strategy string
+}
+
+// Split slices quantity across targets in strategy rank order, giving each
+// target up to its declared capacity before moving to the next one. Targets
+// without a declared capacity absorb the whole remainder. Targets with an
+// open circuit are skipped and unhealthy targets are only used when no
+// healthy target exists, mirroring Select. Children that a skipped target
+// would have ranked ahead of report "circuit-open" as their reason.
+func Split(targets []Target, quantity int64, opts Options) (SplitDecision, error) {
+    if len(targets) == 0 {
+        return SplitDecision{}, ErrNoTargets
+    }
+    if quantity <= 0 {
+        return SplitDecision{}, ErrInvalidQuantity
+    }
+
+    strategy := opts.strategy()
+    now := opts.now()
+
+    allowed, tripped := opts.Breakers.partition(targets, now)
+    if len(allowed) == 0 {
+        return SplitDecision{}, ErrAllCircuitsOpen
+    }
+
+    pool := healthyTargets(allowed)
+    fallback := len(pool) == 0
+    if fallback {
+        pool = allowed
+    }
+
+    remaining := quantity
+    children := make([]Allocation, 0, len(pool))
+    for _, candidate := range strategy.Rank(pool) {
+        if remaining == 0 {
+            break
+        }
+        if !opts.Breakers.tryAcquire(candidate.Target.ID, now) {
+            tripped = append(tripped, candidate.Target)
+            continue
+        }
+        allocated := remaining
+        reason := "remainder"
+        if candidate.Target.Capacity > 0 && candidate.Target.Capacity < remaining {
+            allocated = candidate.Target.Capacity
+            reason = "capacity-filled"
+        }
+        if len(children) == 0 && allocated == quantity {
+            reason = strategy.Name()
+        }
+        if fallback {
+            reason = "fallback-no-healthy-targets"
+        }
+        children = append(children, Allocation{
+            Target:   candidate.Target,
+            Quantity: allocated,
+            Score:    candidate.Score,
+            Reason:   reason,
+        })
+        remaining -= allocated
+    }
+
+    if remaining > 0 {
+        for _, child := range children {
+            opts.Breakers.release(child.Target.ID, now)
+        }
+        return SplitDecision{}, ErrInsufficientCapacity
+    }
+    if !fallback {
+        outranked := trippedAhead(strategy, pool, tripped)
+        for i := range children {
+            if outranked[children[i].Target.ID] {
+                children[i].Reason = "circuit-open"
+            }
+        }
+    }
+
+    return SplitDecision{
+        Children: children,
+        Fallback: fallback,
+        Strategy: strategy.Name(),
+    }, nil
+}
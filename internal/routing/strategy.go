@@ -0,0 +1,205 @@
+package routing
+
+import (
+    "errors"
+    "sort"
+    "sync"
+    "sync/atomic"
+)
+
+// DefaultStrategy is used when a request does not name a strategy.
+const DefaultStrategy = "best-latency"
+
+var ErrUnknownStrategy = errors.New("unknown routing strategy")
+
+// Strategy orders candidate targets from most to least preferred. Rank is
+// always called with at least one candidate and must return every candidate.
+type Strategy interface {
+    Name() string
+    Rank(candidates []Target) []Ranked
+}
+
+// previewer is implemented by stateful strategies that can rank candidates
+// without advancing their state, so a decision can be explained by ranking a
+// second, hypothetical candidate set.
+type previewer interface {
+    preview(candidates []Target) []Ranked
+}
+
+// preview ranks candidates with strategy without side effects where the
+// strategy supports it. Stateless strategies simply rank.
+func preview(strategy Strategy, candidates []Target) []Ranked {
+    if previewer, ok := strategy.(previewer); ok {
+        return previewer.preview(candidates)
+    }
+    return strategy.Rank(candidates)
+}
+
+type Ranked struct {
+    Target    Target
+    Score     float64
+    Breakdown *Breakdown
+    // DecidedBy names the criterion that placed this entry ahead of the next
+    // one, such as "latency" or the "availability" tie-breaker.
+    DecidedBy string
+}
+
+// BestLatency prefers the fastest target. The score is the latency in ms.
+type BestLatency struct{}
+
+func (BestLatency) Name() string { return "best-latency" }
+
+func (BestLatency) Rank(candidates []Target) []Ranked {
+    ranked := rankBy(candidates, compareLatency)
+    for i := range ranked {
+        ranked[i].Score = float64(ranked[i].Target.LatencyMs)
+    }
+    return ranked
+}
+
+// PriorityFirst prefers the lowest priority value and falls back to latency
+// ordering between targets of equal priority. The score is the priority.
+type PriorityFirst struct{}
+
+func (PriorityFirst) Name() string { return "priority-first" }
+
+func (PriorityFirst) Rank(candidates []Target) []Ranked {
+    ranked := rankBy(candidates, func(a, b Target) (int, string) {
+        if a.Priority != b.Priority {
+            if a.Priority < b.Priority {
+                return -1, "priority"
+            }
+            return 1, "priority"
+        }
+        return compareLatency(a, b)
+    })
+    for i := range ranked {
+        ranked[i].Score = float64(ranked[i].Target.Priority)
+    }
+    return ranked
+}
+
+// RoundRobin rotates through the candidates, ordered by ID, on every call.
+// The score is the target's position in the rotation.
+type RoundRobin struct {
+    next atomic.Uint64
+}
+
+func NewRoundRobin() *RoundRobin {
+    return &RoundRobin{}
+}
+
+func (*RoundRobin) Name() string { return "round-robin" }
+
+func (r *RoundRobin) Rank(candidates []Target) []Ranked {
+    return r.rotate(candidates, r.next.Add(1)-1)
+}
+
+// preview ranks candidates at the step the last Rank call used.
+func (r *RoundRobin) preview(candidates []Target) []Ranked {
+    return r.rotate(candidates, r.next.Load()-1)
+}
+
+func (r *RoundRobin) rotate(candidates []Target, step uint64) []Ranked {
+    ordered := rankBy(candidates, func(a, b Target) (int, string) {
+        switch {
+        case a.ID < b.ID:
+            return -1, "id"
+        case a.ID > b.ID:
+            return 1, "id"
+        }
+        return 0, "input-order"
+    })
+    offset := int(step % uint64(len(ordered)))
+    ranked := make([]Ranked, 0, len(ordered))
+    for i := range ordered {
+        entry := ordered[(offset+i)%len(ordered)]
+        entry.Score = float64(i)
+        entry.DecidedBy = "rotation"
+        ranked = append(ranked, entry)
+    }
+    if len(ranked) == 1 {
+        ranked[0].DecidedBy = "only-candidate"
+    }
+    return ranked
+}
+
+func rankBy(candidates []Target, compare func(a, b Target) (int, string)) []Ranked {
+    ranked := make([]Ranked, 0, len(candidates))
+    for _, target := range candidates {
+        ranked = append(ranked, Ranked{Target: target})
+    }
+    sortRanked(ranked, func(a, b Ranked) (int, string) {
+        return compare(a.Target, b.Target)
+    })
+    return ranked
+}
+
+// sortRanked stably sorts ranked and records on each entry the criterion
+// that separated it from its successor.
+func sortRanked(ranked []Ranked, compare func(a, b Ranked) (int, string)) {
+    sort.SliceStable(ranked, func(i, j int) bool {
+        order, _ := compare(ranked[i], ranked[j])
+        return order < 0
+    })
+    if len(ranked) == 1 {
+        ranked[0].DecidedBy = "only-candidate"
+        return
+    }
+    for i := 0; i+1 < len(ranked); i++ {
+        _, ranked[i].DecidedBy = compare(ranked[i], ranked[i+1])
+    }
+}
+
+// Registry maps strategy names to implementations. Stateful strategies such
+// as round-robin keep their state for the lifetime of the registry.
+type Registry struct {
+    mu         sync.RWMutex
+    strategies map[string]Strategy
+}
+
+func NewRegistry(strategies ...Strategy) *Registry {
+    registry := &Registry{strategies: make(map[string]Strategy, len(strategies))}
+    for _, strategy := range strategies {
+        registry.Register(strategy)
+    }
+    return registry
+}
+
+// DefaultRegistry returns a registry holding every built-in strategy, with
+// weighted-score using DefaultWeights.
+func DefaultRegistry() *Registry {
+    return NewRegistry(BestLatency{}, PriorityFirst{}, NewRoundRobin(), NewWeightedScore(DefaultWeights()))
+}
+
+func (r *Registry) Register(strategy Strategy) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.strategies[strategy.Name()] = strategy
+}
+
+// Lookup returns the named strategy. An empty name resolves to
+// DefaultStrategy.
+func (r *Registry) Lookup(name string) (Strategy, error) {
+    if name == "" {
+        name = DefaultStrategy
+    }
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    strategy, ok := r.strategies[name]
+    if !ok {
+        return nil, ErrUnknownStrategy
+    }
+    return strategy, nil
+}
+
+func (r *Registry) Names() []string {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    names := make([]string, 0, len(r.strategies))
+    for name := range r.strategies {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
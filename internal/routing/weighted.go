@@ -0,0 +1,176 @@
+package routing
+
+import (
+    "errors"
+    "math"
+    "strconv"
+    "strings"
+)
+
+var ErrInvalidWeights = errors.New("invalid scoring weights")
+
+// Weights controls how much each normalized factor contributes to a
+// weighted-score decision. Weights are relative and need not sum to one.
+type Weights struct {
+    Latency      float64
+    Availability float64
+    Priority     float64
+    Fee          float64
+    FillRate     float64
+}
+
+// DefaultWeights favours latency and availability, with fees, fill rate and
+// priority acting as secondary signals.
+func DefaultWeights() Weights {
+    return Weights{
+        Latency:      0.35,
+        Availability: 0.25,
+        Priority:     0.1,
+        Fee:          0.15,
+        FillRate:     0.15,
+    }
+}
+
+func (w Weights) Validate() error {
+    for _, value := range []float64{w.Latency, w.Availability, w.Priority, w.Fee, w.FillRate} {
+        if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+            return ErrInvalidWeights
+        }
+    }
+    if w.total() == 0 {
+        return ErrInvalidWeights
+    }
+    return nil
+}
+
+func (w Weights) total() float64 {
+    return w.Latency + w.Availability + w.Priority + w.Fee + w.FillRate
+}
+
+// ParseWeights reads weights from a "factor=value" comma separated list such
+// as "latency=0.5,availability=0.5". Omitted factors get a weight of zero.
+func ParseWeights(raw string) (Weights, error) {
+    var weights Weights
+    for _, part := range strings.Split(raw, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
+        key, value, ok := strings.Cut(part, "=")
+        if !ok {
+            return Weights{}, ErrInvalidWeights
+        }
+        parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+        if err != nil {
+            return Weights{}, ErrInvalidWeights
+        }
+        switch strings.TrimSpace(key) {
+        case "latency":
+            weights.Latency = parsed
+        case "availability":
+            weights.Availability = parsed
+        case "priority":
+            weights.Priority = parsed
+        case "fee":
+            weights.Fee = parsed
+        case "fillRate":
+            weights.FillRate = parsed
+        default:
+            return Weights{}, ErrInvalidWeights
+        }
+    }
+    if err := weights.Validate(); err != nil {
+        return Weights{}, err
+    }
+    return weights, nil
+}
+
+// Breakdown holds each factor's weighted contribution to a score. The
+// contributions sum to the score.
+type Breakdown struct {
+    Latency      float64
+    Availability float64
+    Priority     float64
+    Fee          float64
+    FillRate     float64
+}
+
+// WeightedScore normalizes every factor to [0, 1] across the candidate set,
+// where 1 is best, and combines them with the configured weights. Scores are
+// in [0, 1] and, unlike the other strategies, higher is better.
+type WeightedScore struct {
+    weights Weights
+}
+
+func NewWeightedScore(weights Weights) *WeightedScore {
+    return &WeightedScore{weights: weights}
+}
+
+func (*WeightedScore) Name() string { return "weighted-score" }
+
+func (s *WeightedScore) Weights() Weights {
+    return s.weights
+}
+
+func (s *WeightedScore) Rank(candidates []Target) []Ranked {
+    latency := newSpan()
+    priority := newSpan()
+    fee := newSpan()
+    for _, target := range candidates {
+        latency.add(float64(target.LatencyMs))
+        priority.add(float64(target.Priority))
+        fee.add(target.FeeBps)
+    }
+
+    total := s.weights.total()
+    ranked := make([]Ranked, 0, len(candidates))
+    for _, target := range candidates {
+        breakdown := Breakdown{
+            Latency:      s.weights.Latency * latency.lowerIsBetter(float64(target.LatencyMs)) / total,
+            Availability: s.weights.Availability * clamp01(target.Availability) / total,
+            Priority:     s.weights.Priority * priority.lowerIsBetter(float64(target.Priority)) / total,
+            Fee:          s.weights.Fee * fee.lowerIsBetter(target.FeeBps) / total,
+            FillRate:     s.weights.FillRate * clamp01(target.FillRate) / total,
+        }
+        score := breakdown.Latency + breakdown.Availability + breakdown.Priority + breakdown.Fee + breakdown.FillRate
+        ranked = append(ranked, Ranked{Target: target, Score: score, Breakdown: &breakdown})
+    }
+
+    sortRanked(ranked, func(a, b Ranked) (int, string) {
+        if a.Score != b.Score {
+            if a.Score > b.Score {
+                return -1, "score"
+            }
+            return 1, "score"
+        }
+        return compareLatency(a.Target, b.Target)
+    })
+    return ranked
+}
+
+type span struct {
+    min float64
+    max float64
+}
+
+func newSpan() span {
+    return span{min: math.Inf(1), max: math.Inf(-1)}
+}
+
+func (s *span) add(value float64) {
+    s.min = math.Min(s.min, value)
+    s.max = math.Max(s.max, value)
+}
+
+// lowerIsBetter maps value onto [0, 1] where the smallest observed value
+// scores 1. A degenerate span scores every candidate 1.
+func (s span) lowerIsBetter(value float64) float64 {
+    if s.max <= s.min {
+        return 1
+    }
+    return (s.max - value) / (s.max - s.min)
+}
+
+func clamp01(value float64) float64 {
+    return math.Max(0, math.Min(1, value))
+}
@@ -0,0 +1,101 @@
+// Package tenant describes the isolated client groups the engine serves and
+// the limits configured for each.
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// DefaultID names, in configuration, the tenant of callers whose credentials
+// carry no tenant. Internally that tenant's ID is the empty string, so audit
+// entries recorded before tenants existed belong to it.
+const DefaultID = "default"
+
+var (
+	ErrUnknownTenant = errors.New("unknown tenant")
+	ErrInvalidConfig = errors.New("invalid tenant config")
+)
+
+type Tenant struct {
+	ID string `json:"id"`
+	// Venues lists the target IDs the tenant may route to. Empty allows any.
+	Venues []string `json:"venues,omitempty"`
+	// RequestsPerMin caps the tenant's combined request rate across all of
+	// its clients. Zero leaves only the per-client limit.
+	RequestsPerMin int `json:"requestsPerMin,omitempty"`
+}
+
+// AllowsVenue reports whether the tenant may route to targetID.
+func (t Tenant) AllowsVenue(targetID string) bool {
+	if len(t.Venues) == 0 {
+		return true
+	}
+	for _, venue := range t.Venues {
+		if venue == targetID {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry resolves tenant IDs to their configuration. A nil Registry, used
+// when no tenants are configured, accepts any tenant with no venue or quota
+// restrictions.
+type Registry struct {
+	tenants map[string]Tenant
+}
+
+// NewRegistry indexes tenants by ID. A tenant with ID DefaultID configures
+// callers without a tenant.
+func NewRegistry(tenants []Tenant) (*Registry, error) {
+	registry := &Registry{tenants: make(map[string]Tenant, len(tenants))}
+	for _, tenant := range tenants {
+		if tenant.ID == "" {
+			return nil, fmt.Errorf("%w: tenant id is required", ErrInvalidConfig)
+		}
+		if tenant.RequestsPerMin < 0 {
+			return nil, fmt.Errorf("%w: tenant %q has a negative rate", ErrInvalidConfig, tenant.ID)
+		}
+		id := tenant.ID
+		if id == DefaultID {
+			id = ""
+		}
+		if _, ok := registry.tenants[id]; ok {
+			return nil, fmt.Errorf("%w: duplicate tenant %q", ErrInvalidConfig, tenant.ID)
+		}
+		tenant.ID = id
+		registry.tenants[id] = tenant
+	}
+	return registry, nil
+}
+
+// LoadRegistry reads {"tenants": [...]} from path.
+func LoadRegistry(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var document struct {
+		Tenants []Tenant `json:"tenants"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return NewRegistry(document.Tenants)
+}
+
+// Lookup returns the configuration of tenant id, where "" is the default
+// tenant.
+func (r *Registry) Lookup(id string) (Tenant, error) {
+	if r == nil {
+		return Tenant{ID: id}, nil
+	}
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return Tenant{}, ErrUnknownTenant
+	}
+	return tenant, nil
+}
@@ -0,0 +1,42 @@
+package tenant
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	config := `{"tenants": [
+		{"id": "default"},
+		{"id": "acme", "venues": ["nyse", "arca"], "requestsPerMin": 600}
+	]}`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	acme, err := registry.Lookup("acme")
+	if err != nil || acme.RequestsPerMin != 600 || !acme.AllowsVenue("arca") || acme.AllowsVenue("bats") {
+		t.Fatalf("unexpected acme tenant %+v (%v)", acme, err)
+	}
+	if fallback, err := registry.Lookup(""); err != nil || fallback.ID != "" || !fallback.AllowsVenue("bats") {
+		t.Fatalf("expected the default tenant under the empty id, got %+v (%v)", fallback, err)
+	}
+	if _, err := registry.Lookup("globex"); !errors.Is(err, ErrUnknownTenant) {
+		t.Fatalf("expected ErrUnknownTenant, got %v", err)
+	}
+
+	var open *Registry
+	if anyone, err := open.Lookup("globex"); err != nil || anyone.ID != "globex" {
+		t.Fatalf("expected a nil registry to accept any tenant, got %+v (%v)", anyone, err)
+	}
+	if _, err := NewRegistry([]Tenant{{ID: "acme"}, {ID: "acme"}}); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expected duplicate tenants to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+// Package tlsconfig builds the server's TLS configuration and keeps the
+// certificate and client CA bundle current as the files on disk change.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ClientAuth values for Config.ClientAuth.
+const (
+	ClientAuthRequire  = "require"
+	ClientAuthOptional = "optional"
+)
+
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mutual TLS: client certificates are verified
+	// against this PEM bundle.
+	ClientCAFile string
+	// ClientAuth is ClientAuthRequire (the default when ClientCAFile is set)
+	// or ClientAuthOptional, which verifies a certificate only if one is sent.
+	ClientAuth string
+}
+
+// Reloader serves the most recently loaded certificate and client CAs. It is
+// safe for concurrent use.
+type Reloader struct {
+	config   Config
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	modTimes map[string]time.Time
+}
+
+// NewReloader loads the configured files once, failing if any is missing or
+// invalid.
+func NewReloader(config Config) (*Reloader, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("tlsconfig: cert and key files are required")
+	}
+	switch config.ClientAuth {
+	case "":
+		config.ClientAuth = ClientAuthRequire
+	case ClientAuthRequire, ClientAuthOptional:
+	default:
+		return nil, fmt.Errorf("tlsconfig: unknown client auth mode %q", config.ClientAuth)
+	}
+	reloader := &Reloader{config: config}
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// Reload reads every configured file again. On error the previously loaded
+// material stays in use.
+func (r *Reloader) Reload() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tlsconfig: load key pair: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		data, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("tlsconfig: read client ca: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("tlsconfig: no certificates in %s", r.config.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCA = pool
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+// TLSConfig returns a server configuration that picks up reloaded material
+// on every new handshake.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+			}
+			if r.clientCA != nil {
+				config.ClientCAs = r.clientCA
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+				if r.config.ClientAuth == ClientAuthOptional {
+					config.ClientAuth = tls.VerifyClientCertIfGiven
+				}
+			}
+			return config, nil
+		},
+	}
+}
+
+// MutualTLS reports whether client certificates are verified.
+func (r *Reloader) MutualTLS() bool {
+	return r.config.ClientCAFile != ""
+}
+
+// Watch polls the files every interval and reloads when any modification
+// time changes, until ctx is done. Failed reloads are logged and retried on
+// the next change.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current, changed := r.changed()
+		if !changed {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			// Remember the broken files so they are logged once and the
+			// next write to any of them triggers the retry.
+			r.mu.Lock()
+			r.modTimes = current
+			r.mu.Unlock()
+			log.Printf("tls reload failed, keeping previous certificate: %v", err)
+			continue
+		}
+		log.Printf("tls certificate reloaded")
+	}
+}
+
+// changed stats the files and reports whether any modification time differs
+// from the last loaded or attempted one, along with the current times.
+func (r *Reloader) changed() (map[string]time.Time, bool) {
+	current, err := r.stat()
+	if err != nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for path, modTime := range current {
+		if !modTime.Equal(r.modTimes[path]) {
+			return current, true
+		}
+	}
+	return current, false
+}
+
+func (r *Reloader) stat() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+	for _, path := range []string{r.config.CertFile, r.config.KeyFile, r.config.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("tlsconfig: %w", err)
+		}
+		modTimes[path] = info.ModTime()
+	}
+	return modTimes, nil
+}
@@ -0,0 +1,220 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type issued struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func issue(t *testing.T, name string, serial int64, parent *issued, isCA bool) issued {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return issued{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func write(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMutualTLSAndReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "test-ca", 1, nil, true)
+	server := issue(t, "server-1", 2, &ca, false)
+	client := issue(t, "desk-a", 3, &ca, false)
+
+	config := Config{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	write(t, config.CertFile, server.certPEM)
+	write(t, config.KeyFile, server.keyPEM)
+	write(t, config.ClientCAFile, ca.certPEM)
+
+	reloader, err := NewReloader(config)
+	if err != nil {
+		t.Fatalf("new reloader: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	ts.TLS = reloader.TLSConfig()
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientCert, _ := tls.X509KeyPair(client.certPEM, client.keyPEM)
+	serverSerial := func(certs []tls.Certificate) (int64, error) {
+		httpClient := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: certs},
+			DisableKeepAlives: true,
+		}}
+		response, err := httpClient.Get(ts.URL)
+		if err != nil {
+			return 0, err
+		}
+		defer response.Body.Close()
+		return response.TLS.PeerCertificates[0].SerialNumber.Int64(), nil
+	}
+
+	if _, err := serverSerial(nil); err == nil {
+		t.Fatal("expected handshake without a client certificate to fail")
+	}
+	serial, err := serverSerial([]tls.Certificate{clientCert})
+	if err != nil || serial != 2 {
+		t.Fatalf("expected server certificate 2, got %d (%v)", serial, err)
+	}
+
+	rotated := issue(t, "server-2", 4, &ca, false)
+	write(t, config.CertFile, rotated.certPEM)
+	write(t, config.KeyFile, rotated.keyPEM)
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(config.CertFile, future, future)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, 10*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		serial, err = serverSerial([]tls.Certificate{clientCert})
+		if err == nil && serial == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected reloaded certificate 4, got %d (%v)", serial, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReloadKeepsPreviousCertificateOnError(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "test-ca", 1, nil, true)
+	server := issue(t, "server-1", 2, &ca, false)
+	config := Config{CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key")}
+	write(t, config.CertFile, server.certPEM)
+	write(t, config.KeyFile, server.keyPEM)
+
+	reloader, err := NewReloader(config)
+	if err != nil {
+		t.Fatalf("new reloader: %v", err)
+	}
+	write(t, config.KeyFile, []byte("not a key"))
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("expected reload of a broken key to fail")
+	}
+	served, err := reloader.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil || !bytes.Equal(served.Certificates[0].Certificate[0], server.cert.Raw) {
+		t.Fatalf("expected previous certificate to stay in use, got %v", err)
+	}
+}
+
+func TestWatchRetriesFailedReloadOnlyAfterTheNextChange(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "test-ca", 1, nil, true)
+	server := issue(t, "server-1", 2, &ca, false)
+	config := Config{CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key")}
+	write(t, config.CertFile, server.certPEM)
+	write(t, config.KeyFile, server.keyPEM)
+
+	reloader, err := NewReloader(config)
+	if err != nil {
+		t.Fatalf("new reloader: %v", err)
+	}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	watch := func(touch func()) string {
+		logs.Reset()
+		touch()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			reloader.Watch(ctx, 5*time.Millisecond)
+		}()
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		<-done
+		return logs.String()
+	}
+
+	future := time.Now().Add(time.Minute)
+	output := watch(func() {
+		write(t, config.KeyFile, []byte("not a key"))
+		_ = os.Chtimes(config.KeyFile, future, future)
+	})
+	if got := strings.Count(output, "tls reload failed"); got != 1 {
+		t.Fatalf("expected one failed reload until the files change again, got %d:\n%s", got, output)
+	}
+
+	output = watch(func() {
+		write(t, config.KeyFile, server.keyPEM)
+		later := future.Add(time.Minute)
+		_ = os.Chtimes(config.KeyFile, later, later)
+	})
+	if !strings.Contains(output, "tls certificate reloaded") || strings.Contains(output, "tls reload failed") {
+		t.Fatalf("expected the fixed key to be reloaded, got:\n%s", output)
+	}
+}
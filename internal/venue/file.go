@@ -0,0 +1,127 @@
+package venue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/atomicfile"
+)
+
+// FileStore is a Store that keeps every venue in memory and rewrites one JSON
+// file on each change. The file is replaced atomically, so a crash leaves
+// either the old or the new registry on disk, never a partial one.
+type FileStore struct {
+	path string
+
+	// mu serializes changes so the file is written in the same order the
+	// in-memory registry changes.
+	mu     sync.Mutex
+	memory *MemoryStore
+}
+
+type fileRecord struct {
+	Tenant string `json:"tenant,omitempty"`
+	Venue
+}
+
+type fileDocument struct {
+	Venues []fileRecord `json:"venues"`
+}
+
+// NewFileStore loads the registry at path, starting empty if the file does
+// not exist yet.
+func NewFileStore(path string) (*FileStore, error) {
+	store := &FileStore{path: path, memory: NewMemoryStore()}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var document fileDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("venue: %s: %w", path, err)
+	}
+	for _, record := range document.Venues {
+		store.memory.put(record.Tenant, record.Venue)
+	}
+	return store, nil
+}
+
+func (s *FileStore) List(tenant string) ([]Venue, error) {
+	return s.memory.List(tenant)
+}
+
+func (s *FileStore) Get(tenant, id string) (Venue, bool, error) {
+	return s.memory.Get(tenant, id)
+}
+
+func (s *FileStore) Create(tenant string, venue Venue) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok, _ := s.memory.Get(tenant, venue.ID); ok {
+		return ErrExists
+	}
+	return s.change(tenant, venue.ID, func() { s.memory.Put(tenant, venue) })
+}
+
+func (s *FileStore) Put(tenant string, venue Venue) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.change(tenant, venue.ID, func() { s.memory.Put(tenant, venue) })
+}
+
+func (s *FileStore) Delete(tenant, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok, _ := s.memory.Get(tenant, id); !ok {
+		return false, nil
+	}
+	err := s.change(tenant, id, func() { s.memory.Delete(tenant, id) })
+	return err == nil, err
+}
+
+func (s *FileStore) Scan(fn func(tenant string, venue Venue) error) error {
+	return s.memory.Scan(fn)
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
+
+// change applies apply in memory and persists the result, restoring the
+// venue's previous state if the file cannot be written.
+func (s *FileStore) change(tenant, id string, apply func()) error {
+	previous, existed, _ := s.memory.Get(tenant, id)
+	apply()
+	if err := s.write(); err != nil {
+		if existed {
+			s.memory.Put(tenant, previous)
+		} else {
+			s.memory.Delete(tenant, id)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) write() error {
+	var document fileDocument
+	_ = s.memory.Scan(func(tenant string, venue Venue) error {
+		document.Venues = append(document.Venues, fileRecord{Tenant: tenant, Venue: venue})
+		return nil
+	})
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(s.path, append(data, '\n'))
+}
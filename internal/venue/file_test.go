@@ -0,0 +1,46 @@
+package venue
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStorePersistsPerTenant(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "venues.json")
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	for _, venue := range []Venue{{ID: "nyse", LatencyMs: 4}, {ID: "arca", LatencyMs: 6}} {
+		if err := store.Create("", venue); err != nil {
+			t.Fatalf("create %s: %v", venue.ID, err)
+		}
+	}
+	if err := store.Create("", Venue{ID: "nyse"}); !errors.Is(err, ErrExists) {
+		t.Fatalf("expected ErrExists, got %v", err)
+	}
+	if err := store.Put("acme", Venue{ID: "nyse", LatencyMs: 9}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if deleted, err := store.Delete("", "arca"); err != nil || !deleted {
+		t.Fatalf("expected arca to be deleted, got %v (%v)", deleted, err)
+	}
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	venues, err := reopened.List("")
+	if err != nil || len(venues) != 1 || venues[0].ID != "nyse" || venues[0].LatencyMs != 4 {
+		t.Fatalf("unexpected default tenant venues %+v (%v)", venues, err)
+	}
+	acme, ok, err := reopened.Get("acme", "nyse")
+	if err != nil || !ok || acme.LatencyMs != 9 {
+		t.Fatalf("expected acme's own nyse venue, got %+v %v (%v)", acme, ok, err)
+	}
+	if target := acme.Target(); target.FillRate != 1 {
+		t.Fatalf("expected venues without a fill rate to fill fully, got %v", target.FillRate)
+	}
+}
@@ -0,0 +1,110 @@
+package venue
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore is the in-memory Store. It loses everything on restart.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	venues map[string]map[string]Venue
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{venues: make(map[string]map[string]Venue)}
+}
+
+func (s *MemoryStore) List(tenant string) ([]Venue, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	venues := make([]Venue, 0, len(s.venues[tenant]))
+	for _, venue := range s.venues[tenant] {
+		venues = append(venues, venue)
+	}
+	sort.Slice(venues, func(i, j int) bool { return venues[i].ID < venues[j].ID })
+	return venues, nil
+}
+
+func (s *MemoryStore) Get(tenant, id string) (Venue, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	venue, ok := s.venues[tenant][id]
+	return venue, ok, nil
+}
+
+func (s *MemoryStore) Create(tenant string, venue Venue) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.venues[tenant][venue.ID]; ok {
+		return ErrExists
+	}
+	s.put(tenant, venue)
+	return nil
+}
+
+func (s *MemoryStore) Put(tenant string, venue Venue) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.put(tenant, venue)
+	return nil
+}
+
+func (s *MemoryStore) Delete(tenant, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.delete(tenant, id), nil
+}
+
+func (s *MemoryStore) Scan(fn func(tenant string, venue Venue) error) error {
+	s.mu.RLock()
+	var records []fileRecord
+	for tenant, venues := range s.venues {
+		for _, venue := range venues {
+			records = append(records, fileRecord{Tenant: tenant, Venue: venue})
+		}
+	}
+	s.mu.RUnlock()
+
+	sortRecords(records)
+	for _, record := range records {
+		if err := fn(record.Tenant, record.Venue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// sortRecords orders venues by tenant, then ID.
+func sortRecords(records []fileRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Tenant != records[j].Tenant {
+			return records[i].Tenant < records[j].Tenant
+		}
+		return records[i].ID < records[j].ID
+	})
+}
+
+func (s *MemoryStore) put(tenant string, venue Venue) {
+	if s.venues[tenant] == nil {
+		s.venues[tenant] = make(map[string]Venue)
+	}
+	s.venues[tenant][venue.ID] = venue
+}
+
+func (s *MemoryStore) delete(tenant, id string) bool {
+	if _, ok := s.venues[tenant][id]; !ok {
+		return false
+	}
+	delete(s.venues[tenant], id)
+	return true
+}
@@ -0,0 +1,137 @@
+package venue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+const postgresSchema = `CREATE TABLE IF NOT EXISTS venues (
+	tenant TEXT NOT NULL,
+	id     TEXT NOT NULL,
+	venue  JSONB NOT NULL,
+	PRIMARY KEY (tenant, id)
+)`
+
+// PostgresStore is a Store keeping venues in the venues table.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates the venues table if needed. The store owns db and
+// closes it on Close.
+func NewPostgresStore(ctx context.Context, db *sql.DB) (*PostgresStore, error) {
+	if _, err := db.ExecContext(ctx, postgresSchema); err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) List(tenant string) ([]Venue, error) {
+	rows, err := s.db.Query(`SELECT venue FROM venues WHERE tenant = $1 ORDER BY id`, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	venues := []Venue{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var venue Venue
+		if err := json.Unmarshal(data, &venue); err != nil {
+			return nil, err
+		}
+		venues = append(venues, venue)
+	}
+	return venues, rows.Err()
+}
+
+func (s *PostgresStore) Get(tenant, id string) (Venue, bool, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT venue FROM venues WHERE tenant = $1 AND id = $2`, tenant, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Venue{}, false, nil
+	}
+	if err != nil {
+		return Venue{}, false, err
+	}
+	var venue Venue
+	if err := json.Unmarshal(data, &venue); err != nil {
+		return Venue{}, false, err
+	}
+	return venue, true, nil
+}
+
+func (s *PostgresStore) Create(tenant string, venue Venue) error {
+	data, err := json.Marshal(venue)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(
+		`INSERT INTO venues (tenant, id, venue) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+		tenant, venue.ID, data,
+	)
+	if err != nil {
+		return err
+	}
+	if inserted, err := result.RowsAffected(); err == nil && inserted == 0 {
+		return ErrExists
+	}
+	return nil
+}
+
+func (s *PostgresStore) Put(tenant string, venue Venue) error {
+	data, err := json.Marshal(venue)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO venues (tenant, id, venue) VALUES ($1, $2, $3)
+		 ON CONFLICT (tenant, id) DO UPDATE SET venue = EXCLUDED.venue`,
+		tenant, venue.ID, data,
+	)
+	return err
+}
+
+func (s *PostgresStore) Delete(tenant, id string) (bool, error) {
+	result, err := s.db.Exec(`DELETE FROM venues WHERE tenant = $1 AND id = $2`, tenant, id)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+func (s *PostgresStore) Scan(fn func(tenant string, venue Venue) error) error {
+	rows, err := s.db.Query(`SELECT tenant, venue FROM venues ORDER BY tenant, id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			tenant string
+			data   []byte
+			venue  Venue
+		)
+		if err := rows.Scan(&tenant, &data); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &venue); err != nil {
+			return err
+		}
+		if err := fn(tenant, venue); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,123 @@
+// Package venue keeps the engine's registry of execution venues, the routing
+// targets clients can refer to by ID instead of describing in every request.
+package venue
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/GuilhermeSoares009/smart-order-routing-engine/internal/routing"
+)
+
+var (
+	ErrInvalidVenue = errors.New("invalid venue")
+	ErrExists       = errors.New("venue already exists")
+)
+
+// validID keeps venue IDs safe to use as URL path segments.
+var validID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Venue is a registered routing target. Its metrics are the operator's
+// baseline; the metric cache still blends in observed outcomes.
+type Venue struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	LatencyMs    int64     `json:"latencyMs"`
+	Availability float64   `json:"availability"`
+	Priority     int       `json:"priority"`
+	FeeBps       float64   `json:"feeBps"`
+	FillRate     *float64  `json:"fillRate,omitempty"`
+	Capacity     int64     `json:"capacity"`
+	Probe        *Probe    `json:"probe,omitempty"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// Probe types for Probe.Type.
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+)
+
+// Probe tells the health prober how to check a venue: an HTTP GET of
+// Address, a URL, succeeding on any 2xx or 3xx status, or a TCP connect to
+// Address, a host:port.
+type Probe struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
+}
+
+func (p Probe) Validate() error {
+	switch p.Type {
+	case ProbeHTTP:
+		parsed, err := url.Parse(p.Address)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("%w: probe.address must be an http(s) URL", ErrInvalidVenue)
+		}
+	case ProbeTCP:
+		if _, port, err := net.SplitHostPort(p.Address); err != nil || port == "" {
+			return fmt.Errorf("%w: probe.address must be host:port", ErrInvalidVenue)
+		}
+	default:
+		return fmt.Errorf("%w: probe.type must be 'http' or 'tcp'", ErrInvalidVenue)
+	}
+	return nil
+}
+
+func (v Venue) Validate() error {
+	switch {
+	case !validID.MatchString(v.ID):
+		return fmt.Errorf("%w: id must be 1-64 letters, digits, '.', '_' or '-'", ErrInvalidVenue)
+	case v.LatencyMs < 0:
+		return fmt.Errorf("%w: latencyMs must be >= 0", ErrInvalidVenue)
+	case v.Availability < 0 || v.Availability > 1:
+		return fmt.Errorf("%w: availability must be between 0 and 1", ErrInvalidVenue)
+	case v.FillRate != nil && (*v.FillRate < 0 || *v.FillRate > 1):
+		return fmt.Errorf("%w: fillRate must be between 0 and 1", ErrInvalidVenue)
+	case v.Capacity < 0:
+		return fmt.Errorf("%w: capacity must be >= 0", ErrInvalidVenue)
+	case v.Probe != nil:
+		return v.Probe.Validate()
+	}
+	return nil
+}
+
+// Target converts the venue into a routing candidate. Venues without a fill
+// rate are assumed to fill fully, as request targets are.
+func (v Venue) Target() routing.Target {
+	fillRate := 1.0
+	if v.FillRate != nil {
+		fillRate = *v.FillRate
+	}
+	return routing.Target{
+		ID:           v.ID,
+		Name:         v.Name,
+		LatencyMs:    v.LatencyMs,
+		Availability: v.Availability,
+		Priority:     v.Priority,
+		FeeBps:       v.FeeBps,
+		FillRate:     fillRate,
+		Capacity:     v.Capacity,
+	}
+}
+
+// Store persists venues per tenant, "" being the default tenant.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// List returns the tenant's venues ordered by ID.
+	List(tenant string) ([]Venue, error)
+	Get(tenant, id string) (Venue, bool, error)
+	// Create adds a venue, failing with ErrExists if the ID is taken.
+	Create(tenant string, venue Venue) error
+	// Put creates or replaces a venue.
+	Put(tenant string, venue Venue) error
+	// Delete reports whether a venue was removed.
+	Delete(tenant, id string) (bool, error)
+	// Scan calls fn with every venue of every tenant, stopping at the first
+	// error fn returns.
+	Scan(fn func(tenant string, venue Venue) error) error
+	Close() error
+}